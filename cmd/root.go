@@ -22,10 +22,14 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
 	"runtime/pprof"
 	"runtime/trace"
+	"syscall"
 
 	"github.com/fhquthpdw/yfind/pkg/yfind"
 
@@ -43,7 +47,7 @@ var cfgFile string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "yfind",
+	Use:   "yfind [flags] [PATTERN] [PATH...]",
 	Short: "A brief description of your application",
 	Long: `A longer description that spans multiple lines and likely contains
 examples and usage of using your application. For example:
@@ -53,7 +57,8 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
-	Run: Run,
+	Args: cobra.ArbitraryArgs,
+	Run:  Run,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -83,9 +88,48 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&fileSizeGreater, "size-greater", "", "limit file size greater: 1k|2m|3g")
 	rootCmd.PersistentFlags().StringVar(&fileSizeLess, "size-less", "", "limit file size less: 1k|2m|3g")
 	rootCmd.PersistentFlags().StringVar(&fileType, "type", "", "limit file type: txt,go")
+	rootCmd.PersistentFlags().StringVar(&kinds, "kind", "", "limit entry kind: f,d,l,p,s,b,c (file, dir, symlink, fifo, socket, block/char device), x broken symlink")
 	rootCmd.PersistentFlags().StringVar(&fileName, "name", "", "search file name")
+	rootCmd.PersistentFlags().StringVar(&fileGlob, "glob", "", "search file name by glob: *_test.go, **/migrations/*.sql, {a,b}.yaml")
+	rootCmd.PersistentFlags().StringVar(&globMode, "glob-mode", "", "match glob against: base|rel|abs, default base, or rel if the glob has /")
+	rootCmd.PersistentFlags().StringVar(&newer, "newer", "", "modified after: 90m|7d|2w, 2021-01-02 15:04:05, or a reference file")
+	rootCmd.PersistentFlags().StringVar(&older, "older", "", "modified before: 90m|7d|2w, 2021-01-02 15:04:05, or a reference file")
+	rootCmd.PersistentFlags().StringVar(&changedWithin, "changed-within", "", "status changed after: 90m|7d|2w, 2021-01-02 15:04:05, or a reference file")
+	rootCmd.PersistentFlags().StringVar(&accessedBefore, "accessed-before", "", "accessed before: 90m|7d|2w, 2021-01-02 15:04:05, or a reference file")
+	rootCmd.PersistentFlags().StringVar(&owner, "owner", "", "owned by user name or uid")
+	rootCmd.PersistentFlags().StringVar(&group, "group", "", "owned by group name or gid")
+	rootCmd.PersistentFlags().StringVar(&perm, "perm", "", "permission bits: 0644 exactly, -u+x all of, /o+w any of")
+	rootCmd.PersistentFlags().BoolVar(&suid, "suid", false, "setuid bit is set")
+	rootCmd.PersistentFlags().BoolVar(&executable, "executable", false, "executable by the current user")
+	rootCmd.PersistentFlags().BoolVar(&writableByMe, "writable-by-me", false, "writable by the current user")
+	rootCmd.PersistentFlags().StringArrayVar(&excludes, "exclude", nil, "skip files matching glob, repeatable")
+	rootCmd.PersistentFlags().StringArrayVar(&excludeDirs, "exclude-dir", nil, "skip directories matching glob, repeatable: node_modules, vendor, .git")
+	rootCmd.PersistentFlags().StringArrayVar(&includes, "include", nil, "only search files matching glob, repeatable")
+	rootCmd.PersistentFlags().IntVarP(&afterContext, "after-context", "A", 0, "show NUM lines after each match")
+	rootCmd.PersistentFlags().IntVarP(&beforeContext, "before-context", "B", 0, "show NUM lines before each match")
+	rootCmd.PersistentFlags().IntVarP(&aroundContext, "context", "C", 0, "show NUM lines before and after each match")
+	rootCmd.PersistentFlags().IntVar(&maxColumns, "max-columns", 0, "show a window of NUM bytes around the match for longer lines, 0 is no limit")
+	rootCmd.PersistentFlags().StringVar(&binaryMode, "binary", yfilter.BinarySkip, "binary files in content search: skip|text|report")
+	rootCmd.PersistentFlags().IntVar(&maxDepth, "max-depth", -1, "descend at most NUM levels below the root, -1 is no limit")
+	rootCmd.PersistentFlags().IntVar(&minDepth, "min-depth", 0, "ignore results less than NUM levels below the root")
+	rootCmd.PersistentFlags().BoolVar(&noIgnore, "no-ignore", false, "do not respect .gitignore, .ignore and .yfindignore files")
+	rootCmd.PersistentFlags().BoolVar(&follow, "follow", false, "follow symlinks, loops and dangling links are reported as warnings. symlinked roots are always followed")
+	rootCmd.PersistentFlags().StringVar(&filesFrom, "files-from", "", "search the files listed in FILE instead of walking the roots, - reads the list from stdin")
+	rootCmd.PersistentFlags().BoolVarP(&nullData, "null", "0", false, "file list of --files-from is NUL delimited, e.g. find -print0 or git diff -z")
+	rootCmd.PersistentFlags().BoolVar(&hidden, "hidden", false, "search hidden files and directories")
+	rootCmd.PersistentFlags().IntVar(&threads, "threads", 0, "number of directory walkers and content scan workers (default number of CPUs)")
+	rootCmd.PersistentFlags().BoolVar(&showStats, "stats", false, "print statistics summary: dirs, files, skipped files, matches, bytes read")
+	rootCmd.PersistentFlags().BoolVarP(&filesWithMatches, "files-with-matches", "l", false, "only print paths of files with matches")
+	rootCmd.PersistentFlags().BoolVarP(&filesWithoutMatch, "files-without-match", "L", false, "only print paths of files searched without match")
+	rootCmd.PersistentFlags().BoolVar(&plain, "plain", false, "only print bare paths, without size and colour")
+	rootCmd.PersistentFlags().BoolVar(&print0, "print0", false, "only print bare paths terminated by NUL, for xargs -0")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "output JSON Lines, compatible with ripgrep --json")
+	rootCmd.PersistentFlags().BoolVar(&sortOutput, "sort", false, "output results sorted by path, deterministic but only after the search is finished")
+	rootCmd.PersistentFlags().StringVar(&where, "where", "", "filter expression with and, or, not and parentheses, e.g. \"(glob '*.go' or size +1M) and not path 'testdata/**'\"")
 	rootCmd.PersistentFlags().StringVar(&fileContent, "content", "", "search file content")
-	rootCmd.PersistentFlags().BoolVar(&cC, "no-cc", true, "case sensitive")
+	rootCmd.PersistentFlags().BoolVar(&noCC, "no-cc", false, "case insensitive")
+	rootCmd.PersistentFlags().BoolVarP(&smartCase, "smart-case", "S", false, "case insensitive unless the pattern has uppercase")
+	rootCmd.PersistentFlags().BoolVarP(&regex, "regex", "e", false, "treat content as RE2 regular expression")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -102,6 +146,7 @@ func initConfig() {
 		}
 
 		// Search config in home directory with name ".yfind" (without extension).
+		// default exclusion lists can be set by "exclude" and "exclude-dir" keys
 		viper.AddConfigPath(home)
 		viper.SetConfigName(".yfind")
 	}
@@ -110,23 +155,103 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
 
 ///// YFind Run /////
 var (
-	path            string
-	fileSizeGreater string
-	fileSizeLess    string
-	fileType        string
-	fileName        string
-	fileContent     string
-	cC              bool
+	path              string
+	fileSizeGreater   string
+	fileSizeLess      string
+	fileType          string
+	kinds             string
+	where             string
+	fileName          string
+	fileGlob          string
+	newer             string
+	older             string
+	changedWithin     string
+	accessedBefore    string
+	owner             string
+	group             string
+	perm              string
+	suid              bool
+	executable        bool
+	writableByMe      bool
+	globMode          string
+	excludes          []string
+	excludeDirs       []string
+	includes          []string
+	maxDepth          int
+	minDepth          int
+	noIgnore          bool
+	threads           int
+	sortOutput        bool
+	showStats         bool
+	jsonOutput        bool
+	filesWithMatches  bool
+	filesWithoutMatch bool
+	plain             bool
+	print0            bool
+	hidden            bool
+	follow            bool
+	filesFrom         string
+	nullData          bool
+	fileContent       string
+	noCC              bool
+	afterContext      int
+	beforeContext     int
+	aroundContext     int
+	binaryMode        string
+	maxColumns        int
+	smartCase         bool
+	regex             bool
 )
 
 //func Run(cmd *cobra.Command, args []string) {
-func Run(_ *cobra.Command, _ []string) {
+func Run(_ *cobra.Command, args []string) {
+	// SIGINT/SIGTERM stop new work, in-flight results are flushed before exit
+	// a second signal kills the process with the default behavior
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigChan:
+			signal.Stop(sigChan)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := run(ctx, parseArgs(args)); err != nil {
+		os.Exit(130)
+	}
+}
+
+// parseArgs positional arguments PATTERN [PATH...]
+// PATTERN works as --name, PATHs are search roots in addition to --path
+func parseArgs(args []string) (roots []string) {
+	if path != "" {
+		roots = append(roots, path)
+	}
+	if len(args) == 0 {
+		return roots
+	}
+	if args[0] != "" {
+		if fileName != "" {
+			log.Fatalf("PATTERN and --name can not be both set")
+		}
+		fileName = args[0]
+	}
+	return append(roots, args[1:]...)
+}
+
+// run run yfind, profiling files are always closed before return
+func run(ctx context.Context, roots []string) error {
 	// trace
 	// go tool trace --http=':8080' ./pprof/trace.out
 	fTrace, _ := os.Create("./pprof/trace.out")
@@ -150,10 +275,53 @@ func Run(_ *cobra.Command, _ []string) {
 		fGR.Close()
 	}()
 
-	yFilter := yfilter.NewFilter(yfilter.NewFilterCfg(fileSizeGreater, fileSizeLess, fileType, fileName, fileContent, cC))
-	yOutput := youtput.NewOutput(fileName, fileContent)
+	yFilter := yfilter.NewFilter(yfilter.NewFilterCfg(fileSizeGreater, fileSizeLess, fileType, fileName, fileContent, !noCC, smartCase, regex).
+		SetGlob(fileGlob, globMode).
+		SetPathRules(
+			append(viper.GetStringSlice("exclude"), excludes...),
+			append(viper.GetStringSlice("exclude-dir"), excludeDirs...),
+			includes,
+		).
+		SetContext(beforeContext, afterContext, aroundContext).
+		SetBinaryMode(binaryMode).
+		SetMaxColumns(maxColumns).
+		SetTimeFilters(newer, older, changedWithin, accessedBefore).
+		SetOwnership(owner, group).
+		SetPermissions(perm, suid, executable, writableByMe).
+		SetKinds(kinds).
+		SetWhere(where).
+		SetListFiles(filesWithMatches, filesWithoutMatch))
+	outputMode := youtput.ModeColor
+	pathsOnly := plain || print0 || filesWithMatches || filesWithoutMatch
+	if jsonOutput && pathsOnly {
+		log.Fatalf("--json can not be used with --plain, --print0, -l or -L")
+	}
+	if jsonOutput {
+		outputMode = youtput.ModeJSON
+	} else if pathsOnly {
+		outputMode = youtput.ModePlain
+	}
+	yOutput := youtput.NewOutput(fileName, fileContent).
+		SetSort(sortOutput).
+		SetMode(outputMode).
+		SetPrint0(print0).
+		SetShowContext(afterContext > 0 || beforeContext > 0 || aroundContext > 0).
+		SetShowLines(yFilter.SearchesContent())
+	followMode := yfind.FollowNever
+	if follow {
+		followMode = yfind.FollowAll
+	}
 	yFind := yfind.NewYFind(yFilter, yOutput)
-	yFind.SetRootPath(path).Run()
+	return yFind.SetRootPaths(roots).
+		SetThreads(threads).
+		SetDepth(minDepth, maxDepth).
+		SetNoIgnore(noIgnore).
+		SetHidden(hidden).
+		SetFollow(followMode).
+		SetFilesFrom(filesFrom, nullData).
+		SetStdin(len(roots) == 0 && filesFrom == "" && yFilter.SearchesContent() && yfind.StdinPiped()).
+		SetShowStats(showStats).
+		Run(ctx)
 }
 
 /*
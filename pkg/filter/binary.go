@@ -0,0 +1,43 @@
+package yfilter
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// binary modes, what to do with binary files in content search
+const (
+	BinarySkip   = "skip"   // do not search binary files
+	BinaryText   = "text"   // search binary files as text
+	BinaryReport = "report" // search binary files, only report "binary file matches"
+)
+
+// binarySniffSize size of the first block used to detect binary files
+const binarySniffSize = 8 * 1024
+
+// sniffBinary check if the first block of a file is binary
+// a NUL byte means binary, offset is the offset of the first NUL.
+// otherwise the MIME type is sniffed, a non text type with invalid UTF-8 content is binary,
+// the UTF-8 check avoids taking text files beginning with magic bytes like "BM" as images
+func sniffBinary(head []byte) (binary bool, offset int64) {
+	if idx := bytes.IndexByte(head, 0); idx >= 0 {
+		return true, int64(idx)
+	}
+
+	if strings.HasPrefix(http.DetectContentType(head), "text/") {
+		return false, 0
+	}
+	// the block may end in the middle of a rune
+	tail := len(head) - utf8.UTFMax
+	if tail < 0 {
+		tail = 0
+	}
+	for end := len(head); end > tail; end-- {
+		if utf8.Valid(head[:end]) {
+			return false, 0
+		}
+	}
+	return true, 0
+}
@@ -0,0 +1,460 @@
+package yfilter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	youtput "github.com/fhquthpdw/yfind/pkg/output"
+)
+
+// evalEnv one file under evaluation
+type evalEnv struct {
+	ctx     context.Context
+	file    os.FileInfo
+	baseDir string
+	reader  func() io.Reader             // content of a stream instead of the file, see DoFilterReader
+	flush   func([]youtput.FileItemLine) // output hit lines of the stream before it ends, see DoFilterReader
+	content youtput.FileItem             // lines of the content predicates that hit
+	reason  string                       // skip reason of the last failed predicate
+}
+
+// exprNode node of filter expression tree
+type exprNode interface {
+	eval(e *evalEnv) bool
+	cost() int
+}
+
+// cost of predicates, cheaper operand of and/or is evaluated first
+const (
+	costMeta    = 1   // file info only
+	costContent = 100 // reads the file
+)
+
+///// Nodes /////
+
+// predNode predicate on file info
+type predNode struct {
+	fun    filterFunc
+	reason string
+}
+
+func (n *predNode) eval(e *evalEnv) bool {
+	if n.fun(e.file, e.baseDir) == nil {
+		e.reason = n.reason
+		return false
+	}
+	return true
+}
+
+func (n *predNode) cost() int { return costMeta }
+
+// contentNode predicate on file content, the hit lines are kept in evalEnv
+type contentNode struct {
+	f       *Filter
+	matcher matcher
+}
+
+func (n *contentNode) eval(e *evalEnv) bool {
+	item := youtput.FileItem{FileName: e.baseDir + e.file.Name()}
+	var hit bool
+	var reason string
+	if e.reader != nil {
+		hit, reason = n.f.searchReader(e.ctx, e.reader(), n.matcher, &item, e.flush)
+	} else {
+		hit, reason = n.f.searchFile(e.ctx, e.file, n.matcher, &item)
+	}
+	e.content.BytesRead += item.BytesRead
+	e.content.Elapsed += item.Elapsed
+	if !hit {
+		e.reason = reason
+		return false
+	}
+
+	e.content.Lines = mergeLines(e.content.Lines, item.Lines)
+	if item.Binary && !e.content.Binary {
+		e.content.Binary, e.content.BinaryOffset = true, item.BinaryOffset
+	}
+	return true
+}
+
+func (n *contentNode) cost() int { return costContent }
+
+// notNode
+type notNode struct {
+	sub exprNode
+}
+
+// lines of content predicates under it are dropped, they are not hits of the whole expression.
+// content which is not searched, like binary, unreadable or cancelled, is not a missing hit
+func (n *notNode) eval(e *evalEnv) bool {
+	lines, binary, binaryOffset := e.content.Lines, e.content.Binary, e.content.BinaryOffset
+	if n.sub.eval(e) {
+		e.reason = youtput.SkipWhere
+		return false
+	}
+	if unsearched(e.reason) {
+		return false
+	}
+	e.content.Lines, e.content.Binary, e.content.BinaryOffset = lines, binary, binaryOffset
+	return true
+}
+
+func (n *notNode) cost() int { return n.sub.cost() }
+
+// unsearched check if the content predicate failed because the content was not searched to the end,
+// then it is neither a hit nor a missing hit
+func unsearched(reason string) bool {
+	switch reason {
+	case youtput.SkipUnreadable, youtput.SkipBinary, youtput.SkipSpecial, youtput.SkipNotRegular, youtput.SkipCancelled:
+		return true
+	}
+	return false
+}
+
+// noMatchNode for --files-without-match, true if the content is searched without hit
+// files which can not be searched, like binary, unreadable or cancelled ones, are not listed
+type noMatchNode struct {
+	sub exprNode
+}
+
+func (n *noMatchNode) eval(e *evalEnv) bool {
+	if n.sub.eval(e) {
+		e.reason = ""
+		return false
+	}
+	return e.reason == ""
+}
+
+func (n *noMatchNode) cost() int { return n.sub.cost() }
+
+// andNode
+type andNode struct {
+	left, right exprNode
+}
+
+// newAndNode the cheaper operand goes left
+func newAndNode(left, right exprNode) exprNode {
+	if left.cost() > right.cost() {
+		left, right = right, left
+	}
+	return &andNode{left: left, right: right}
+}
+
+func (n *andNode) eval(e *evalEnv) bool {
+	return n.left.eval(e) && n.right.eval(e)
+}
+
+func (n *andNode) cost() int { return n.left.cost() + n.right.cost() }
+
+// orNode
+type orNode struct {
+	left, right exprNode
+}
+
+// newOrNode the cheaper operand goes left
+func newOrNode(left, right exprNode) exprNode {
+	if left.cost() > right.cost() {
+		left, right = right, left
+	}
+	return &orNode{left: left, right: right}
+}
+
+func (n *orNode) eval(e *evalEnv) bool {
+	return n.left.eval(e) || n.right.eval(e)
+}
+
+func (n *orNode) cost() int { return n.left.cost() + n.right.cost() }
+
+// countContent number of content predicates in the tree
+func countContent(node exprNode) int {
+	switch n := node.(type) {
+	case *contentNode:
+		return 1
+	case *notNode:
+		return countContent(n.sub)
+	case *noMatchNode:
+		return countContent(n.sub)
+	case *andNode:
+		return countContent(n.left) + countContent(n.right)
+	case *orNode:
+		return countContent(n.left) + countContent(n.right)
+	}
+	return 0
+}
+
+// streamsContent check if the only content predicate is evaluated last and decides alone,
+// so its hit lines can be output before the whole content is searched
+func streamsContent(n exprNode) bool {
+	for {
+		switch node := n.(type) {
+		case *andNode:
+			if countContent(node.left) > 0 {
+				return false
+			}
+			n = node.right
+		case *contentNode:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// mergeLines merge lines of two content predicates by line number
+func mergeLines(lines, more []youtput.FileItemLine) []youtput.FileItemLine {
+	if len(lines) == 0 {
+		return more
+	}
+
+	all := append(lines, more...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Line < all[j].Line })
+	merged := all[:1]
+	for _, l := range all[1:] {
+		last := &merged[len(merged)-1]
+		if l.Line == last.Line {
+			last.Hit = last.Hit || l.Hit
+			last.Matches = append(last.Matches, l.Matches...)
+			continue
+		}
+		merged = append(merged, l)
+	}
+	return merged
+}
+
+///// Parser /////
+
+// exprParser parse --where expression
+//
+//	expr    = and { "or" and }
+//	and     = unary { ["and"] unary }
+//	unary   = ("not" | "!") unary | "(" expr ")" | predicate [argument]
+//
+// adjacent predicates are ANDed like find
+type exprParser struct {
+	f      *Filter
+	tokens []string
+	pos    int
+}
+
+// parseExpr
+func (f *Filter) parseExpr(where string) (exprNode, error) {
+	tokens, err := tokenizeExpr(where)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{f: f, tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	return node, nil
+}
+
+// peek
+func (p *exprParser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+// next
+func (p *exprParser) next() (string, bool) {
+	tok, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return tok, ok
+}
+
+// parseOr
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if tok, _ := p.peek(); tok != "or" {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = newOrNode(left, right)
+	}
+}
+
+// parseAnd
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok == "or" || tok == ")" {
+			return left, nil
+		}
+		if tok == "and" {
+			p.pos++
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = newAndNode(left, right)
+	}
+}
+
+// parseUnary
+func (p *exprParser) parseUnary() (exprNode, error) {
+	tok, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	switch tok {
+	case "not", "!":
+		sub, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{sub: sub}, nil
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, _ := p.next(); tok != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return node, nil
+	}
+	return p.parsePredicate(tok)
+}
+
+// parsePredicate
+// every predicate works like the flag with the same name, size takes +N for greater and -N for less
+func (p *exprParser) parsePredicate(name string) (exprNode, error) {
+	f := p.f
+	// predicates without argument
+	switch name {
+	case "suid":
+		return f.predicate(func(c *FilterCfg) { c.suid = true }, (*Filter).filterSuid, youtput.SkipPerm), nil
+	case "executable":
+		return f.predicate(func(c *FilterCfg) { c.executable = true }, (*Filter).filterExecutable, youtput.SkipPerm), nil
+	case "writable-by-me":
+		return f.predicate(func(c *FilterCfg) { c.writableByMe = true }, (*Filter).filterWritableByMe, youtput.SkipPerm), nil
+	}
+
+	arg, ok := p.next()
+	if !ok || arg == "(" || arg == ")" {
+		return nil, fmt.Errorf("%s needs an argument", name)
+	}
+	switch name {
+	case "size":
+		if len(arg) < 3 || (arg[0] != '+' && arg[0] != '-') {
+			return nil, fmt.Errorf("size needs +N or -N with unit k|m|g: %s", arg)
+		}
+		if arg[0] == '+' {
+			return f.predicate(func(c *FilterCfg) { c.setFileSizeGreater(arg[1:]) }, (*Filter).filterFileSizeGreater, youtput.SkipSize), nil
+		}
+		return f.predicate(func(c *FilterCfg) { c.setFileSizeLess(arg[1:]) }, (*Filter).filterFileSizeLess, youtput.SkipSize), nil
+	case "type":
+		return f.predicate(func(c *FilterCfg) { c.setFileType(arg) }, (*Filter).filterFileType, youtput.SkipType), nil
+	case "name":
+		return f.predicate(func(c *FilterCfg) { c.setFileName(arg).setNameMatcher() }, (*Filter).filterFileName, youtput.SkipName), nil
+	case "glob":
+		return f.predicate(func(c *FilterCfg) { c.SetGlob(arg, c.globMode) }, (*Filter).filterFileGlob, youtput.SkipName), nil
+	case "path":
+		return f.predicate(func(c *FilterCfg) { c.SetPathRules(nil, nil, []string{arg}) }, (*Filter).filterPathRules, youtput.SkipPath), nil
+	case "kind":
+		f.whereKinds = true
+		return f.predicate(func(c *FilterCfg) { c.SetKinds(arg) }, (*Filter).filterKind, youtput.SkipKind), nil
+	case "newer":
+		return f.predicate(func(c *FilterCfg) { c.newer = c.parseTimeRef(arg, time.Now()) }, (*Filter).filterNewer, youtput.SkipTime), nil
+	case "older":
+		return f.predicate(func(c *FilterCfg) { c.older = c.parseTimeRef(arg, time.Now()) }, (*Filter).filterOlder, youtput.SkipTime), nil
+	case "changed-within":
+		return f.predicate(func(c *FilterCfg) { c.changedWithin = c.parseTimeRef(arg, time.Now()) }, (*Filter).filterChangedWithin, youtput.SkipTime), nil
+	case "accessed-before":
+		return f.predicate(func(c *FilterCfg) { c.accessedBefore = c.parseTimeRef(arg, time.Now()) }, (*Filter).filterAccessedBefore, youtput.SkipTime), nil
+	case "owner":
+		return f.predicate(func(c *FilterCfg) { c.SetOwnership(arg, "") }, (*Filter).filterOwner, youtput.SkipOwner), nil
+	case "group":
+		return f.predicate(func(c *FilterCfg) { c.SetOwnership("", arg) }, (*Filter).filterGroup, youtput.SkipOwner), nil
+	case "perm":
+		return f.predicate(func(c *FilterCfg) { c.SetPermissions(arg, false, false, false) }, (*Filter).filterPerm, youtput.SkipPerm), nil
+	case "content":
+		m := newMatcher(arg, f.Cfg.regex, f.Cfg.caseSensitive, f.Cfg.smartCase)
+		return &contentNode{f: f, matcher: m}, nil
+	}
+	return nil, fmt.Errorf("unknown predicate %q", name)
+}
+
+// predicate bind a flag filter function to a copy of the config with only its argument changed
+// the options like case sensitivity and glob mode are kept from the flags, roots are set by SetRootPaths
+func (f *Filter) predicate(set func(c *FilterCfg), fun func(*Filter, os.FileInfo, string) os.FileInfo, reason string) exprNode {
+	cfg := *f.Cfg
+	set(&cfg)
+	bound := &Filter{Cfg: &cfg, Stats: f.Stats}
+	f.bound = append(f.bound, bound)
+	return &predNode{
+		fun:    func(file os.FileInfo, baseDir string) os.FileInfo { return fun(bound, file, baseDir) },
+		reason: reason,
+	}
+}
+
+// tokenizeExpr split expression by spaces, parentheses are tokens,
+// single or double quotes keep spaces and parentheses in an argument
+func tokenizeExpr(expr string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	inToken := false
+	var quote rune
+
+	flush := func() {
+		if inToken {
+			tokens = append(tokens, token.String())
+			token.Reset()
+			inToken = false
+		}
+	}
+	for _, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				token.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inToken = true
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			token.WriteRune(r)
+			inToken = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+	flush()
+	return tokens, nil
+}
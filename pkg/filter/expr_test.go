@@ -0,0 +1,239 @@
+package yfilter
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTokenizeExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+		err  string
+	}{
+		{expr: "", want: nil},
+		{expr: "glob '*.go' or (size +1M)", want: []string{"glob", "*.go", "or", "(", "size", "+1M", ")"}},
+		{expr: `name "a b"  and not path x`, want: []string{"name", "a b", "and", "not", "path", "x"}},
+		{expr: "path 'x(1)'", want: []string{"path", "x(1)"}},
+		{expr: `a'b c'"d"`, want: []string{"ab cd"}},
+		{expr: "name ''", want: []string{"name", ""}},
+		{expr: "!(glob x)", want: []string{"!", "(", "glob", "x", ")"}},
+		{expr: "glob 'x", err: "unterminated quote '"},
+		{expr: `glob "x`, err: `unterminated quote "`},
+	}
+
+	for _, tt := range tests {
+		got, err := tokenizeExpr(tt.expr)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: got error %v, want %q", tt.expr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: got error %v", tt.expr, err)
+			continue
+		}
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// exprFixture directory with a few files, the directory name has none of the letters used by name predicates
+func exprFixture(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	files := map[string]string{
+		"a.go":   "package a\n",
+		"big.go": strings.Repeat("x", 2048),
+		"b.txt":  "foo\n",
+		"y":      "",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(dir+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(dir+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir + "/"
+}
+
+// evalFixture names of the fixture entries which node is true for
+func evalFixture(t *testing.T, ctx context.Context, node exprNode, dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if node.eval(&evalEnv{ctx: ctx, file: entry, baseDir: dir}) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestParseExpr(t *testing.T) {
+	dir := exprFixture(t)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		expr      string
+		cancelled bool
+		want      []string
+	}{
+		{expr: "glob '*.go'", want: []string{"a.go", "big.go"}},
+		{expr: "glob *.go size +1k", want: []string{"big.go"}},
+		{expr: "glob *.go and size +1k", want: []string{"big.go"}},
+		// and binds tighter than or
+		{expr: "glob 'a*' or glob 'b*' and size +1k", want: []string{"a.go", "big.go"}},
+		{expr: "glob 'b*' and size +1k or glob 'a*'", want: []string{"a.go", "big.go"}},
+		{expr: "(glob 'a*' or glob 'b*') and size +1k", want: []string{"big.go"}},
+		{expr: "((glob 'a*'))", want: []string{"a.go"}},
+		{expr: "not glob '*.go'", want: []string{"b.txt", "sub", "y"}},
+		{expr: "! glob '*.go'", want: []string{"b.txt", "sub", "y"}},
+		{expr: "!(glob '*.go' or glob '*.txt')", want: []string{"sub", "y"}},
+		{expr: "not not glob '*.go'", want: []string{"a.go", "big.go"}},
+		{expr: "not glob '*.go' glob 'b*'", want: []string{"b.txt"}},
+		{expr: "name y", want: []string{"y"}},
+		{expr: "kind d or name y", want: []string{"sub", "y"}},
+		{expr: "kind f and size -1k", want: []string{"a.go", "b.txt", "y"}},
+		{expr: "content foo", want: []string{"b.txt"}},
+		{expr: "content foo or content package", want: []string{"a.go", "b.txt"}},
+		{expr: "glob '*.txt' and not content bar", want: []string{"b.txt"}},
+		// a cancelled search is neither a hit nor a missing hit
+		{expr: "content foo", cancelled: true, want: nil},
+		{expr: "not content foo", cancelled: true, want: nil},
+		{expr: "glob '*.go' or content foo", cancelled: true, want: []string{"a.go", "big.go"}},
+		{expr: "not content foo or name y", cancelled: true, want: []string{"y"}},
+	}
+
+	for _, tt := range tests {
+		f := newTestFilter("", false, 0, 0)
+		node, err := f.parseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%q: got error %v", tt.expr, err)
+			continue
+		}
+		ctx := context.Background()
+		if tt.cancelled {
+			ctx = cancelled
+		}
+		if got := evalFixture(t, ctx, node, dir); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseExprError(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"", "unexpected end of expression"},
+		{"not", "unexpected end of expression"},
+		{"glob x or", "unexpected end of expression"},
+		{"glob x and", "unexpected end of expression"},
+		{"(glob x", "missing )"},
+		{"((glob x) or glob y", "missing )"},
+		{"glob x)", `unexpected ")"`},
+		{"glob x) or (glob y", `unexpected ")"`},
+		{"glob", "glob needs an argument"},
+		{"glob (x)", "glob needs an argument"},
+		{"foo bar", `unknown predicate "foo"`},
+		{"size 1k", "size needs +N or -N with unit k|m|g: 1k"},
+		{"glob 'x", "unterminated quote '"},
+	}
+
+	for _, tt := range tests {
+		f := newTestFilter("", false, 0, 0)
+		if _, err := f.parseExpr(tt.expr); err == nil || err.Error() != tt.err {
+			t.Errorf("%q: got error %v, want %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+// TestExprCost metadata predicates are evaluated before content predicates, whatever the order in the expression
+func TestExprCost(t *testing.T) {
+	tests := []struct {
+		expr      string
+		leftMeta  bool
+		rightMeta bool
+	}{
+		{"content foo and glob x", true, false},
+		{"glob x and content foo", true, false},
+		{"content foo or glob x", true, false},
+		{"(content foo or content bar) and not glob x", true, false},
+		{"content foo and (glob x or size +1k)", true, false},
+		{"glob x and size +1k", true, true},
+	}
+
+	for _, tt := range tests {
+		f := newTestFilter("", false, 0, 0)
+		node, err := f.parseExpr(tt.expr)
+		if err != nil {
+			t.Fatalf("%q: got error %v", tt.expr, err)
+		}
+		var left, right exprNode
+		switch n := node.(type) {
+		case *andNode:
+			left, right = n.left, n.right
+		case *orNode:
+			left, right = n.left, n.right
+		default:
+			t.Fatalf("%q: got %T, want and or or", tt.expr, node)
+		}
+		if (countContent(left) == 0) != tt.leftMeta || (countContent(right) == 0) != tt.rightMeta {
+			t.Errorf("%q: got left cost %d, right cost %d", tt.expr, left.cost(), right.cost())
+		}
+	}
+}
+
+// TestWhereKindSelectsDirs kind in --where replaces the default, which rejects directories
+func TestWhereKindSelectsDirs(t *testing.T) {
+	dir := exprFixture(t)
+	tests := []struct {
+		where    string
+		wantDirs bool
+		want     []string
+	}{
+		{"glob '*'", false, []string{"a.go", "b.txt", "big.go", "y"}},
+		{"kind d", true, []string{"sub"}},
+		{"kind d or name y", true, []string{"sub", "y"}},
+		{"not kind d", true, []string{"a.go", "b.txt", "big.go", "y"}},
+	}
+
+	for _, tt := range tests {
+		f := NewFilter(NewFilterCfg("", "", "", "", "", true, false, false).SetWhere(tt.where))
+		if f.WantDirs() != tt.wantDirs {
+			t.Errorf("%q: got WantDirs %v, want %v", tt.where, f.WantDirs(), tt.wantDirs)
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, entry := range entries {
+			if pass, _ := f.DoFilter(context.Background(), entry, dir); pass {
+				got = append(got, entry.Name())
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.where, got, tt.want)
+		}
+	}
+}
@@ -1,37 +1,78 @@
 package yfilter
 
 import (
-	"bufio"
-	"bytes"
+	"context"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	yglob "github.com/fhquthpdw/yfind/pkg/glob"
 	youtput "github.com/fhquthpdw/yfind/pkg/output"
 )
 
 ////// Filter Config /////
 // filterCfg
 type FilterCfg struct {
-	path            string
+	roots           []string // search roots with trailing "/"
 	fileSizeGreater int64
 	fileSizeLess    int64
 	fileType        map[string]struct{}
 	fileName        string
 	fileContent     string
 	caseSensitive   bool
+	smartCase       bool
+	regex           bool
+	nameMatcher     matcher
+	contentMatcher  matcher
+	glob            *yglob.Glob
+	globMode        string // empty is base, or rel if the glob has "/", like --exclude
+	excludes        []*yglob.Glob
+	excludeDirs     []*yglob.Glob
+	includes        []*yglob.Glob
+	beforeContext   int
+	afterContext    int
+	binaryMode      string
+	maxColumns      int
+	newer           time.Time
+	older           time.Time
+	changedWithin   time.Time
+	accessedBefore  time.Time
+	owner           *uint32
+	group           *uint32
+	perm            *permRule
+	suid            bool
+	executable      bool
+	writableByMe    bool
+	kinds           map[string]struct{}
+	where           string
+	listFiles       bool // only paths are output, content search stops at the first hit
+	withoutMatch    bool
 }
 
+// glob match modes, what part of the path is matched against --glob
+const (
+	GlobModeBase = "base"
+	GlobModeRel  = "rel"
+	GlobModeAbs  = "abs"
+)
+
 // GetFilterCfg
-func NewFilterCfg(fileSizeGreater, fileSizeLess, fileType, fileName, fileContent string, caseSensitive bool) *FilterCfg {
+func NewFilterCfg(fileSizeGreater, fileSizeLess, fileType, fileName, fileContent string, caseSensitive, smartCase, regex bool) *FilterCfg {
 	f := &FilterCfg{}
 	f.setFileSizeGreater(fileSizeGreater).
 		setFileSizeLess(fileSizeLess).
 		setFileType(fileType).
 		setFileName(fileName).
 		setFileContent(fileContent).
-		setCaseSensitive(caseSensitive)
+		setCaseSensitive(caseSensitive).
+		setSmartCase(smartCase).
+		setRegex(regex).
+		setNameMatcher().
+		setContentMatcher()
 	return f
 }
 
@@ -110,10 +151,225 @@ func (c *FilterCfg) setCaseSensitive(cC bool) *FilterCfg {
 	return c
 }
 
+// setSmartCase
+func (c *FilterCfg) setSmartCase(smartCase bool) *FilterCfg {
+	c.smartCase = smartCase
+	return c
+}
+
+// setRegex
+func (c *FilterCfg) setRegex(regex bool) *FilterCfg {
+	c.regex = regex
+	return c
+}
+
+// setNameMatcher compile the name pattern only once
+// name is always matched as literal
+func (c *FilterCfg) setNameMatcher() *FilterCfg {
+	if c.fileName == "" {
+		return c
+	}
+	c.nameMatcher = newMatcher(c.fileName, false, c.caseSensitive, c.smartCase)
+	return c
+}
+
+// SetRootPaths set the search roots, used for path relative matching
+func (c *FilterCfg) SetRootPaths(paths []string) *FilterCfg {
+	c.roots = make([]string, 0, len(paths))
+	for _, p := range paths {
+		c.roots = append(c.roots, strings.TrimRight(p, "/")+"/")
+	}
+	return c
+}
+
+// SetGlob compile glob pattern for file name matching
+// mode is one of base|rel|abs, it is kept without pattern too for glob in --where.
+// without mode, a glob with "/" is matched against the path under root, otherwise against the basename
+func (c *FilterCfg) SetGlob(pattern, mode string) *FilterCfg {
+	switch mode {
+	case "", GlobModeBase, GlobModeRel, GlobModeAbs:
+	default:
+		log.Fatalf("invalid glob mode: %s", mode)
+	}
+	c.globMode = mode
+	if pattern == "" {
+		return c
+	}
+
+	caseSensitive := c.caseSensitive
+	if c.smartCase {
+		caseSensitive = hasUppercase(pattern, false)
+	}
+	g, err := yglob.Compile(pattern, caseSensitive)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	c.glob = g
+	return c
+}
+
+// SetContext set number of context lines before and after each hit line
+// context sets both, before and after override it when they are set
+func (c *FilterCfg) SetContext(before, after, context int) *FilterCfg {
+	c.beforeContext = context
+	c.afterContext = context
+	if before > 0 {
+		c.beforeContext = before
+	}
+	if after > 0 {
+		c.afterContext = after
+	}
+	return c
+}
+
+// SetBinaryMode set what to do with binary files: skip|text|report
+func (c *FilterCfg) SetBinaryMode(mode string) *FilterCfg {
+	switch mode {
+	case "":
+		mode = BinarySkip
+	case BinarySkip, BinaryText, BinaryReport:
+	default:
+		log.Fatalf("invalid binary mode: %s", mode)
+	}
+	c.binaryMode = mode
+	return c
+}
+
+// SetTimeFilters set modification, change and access time limits
+// each one is a duration like 90m|7d|2w, a timestamp like 2021-01-02 15:04:05, or a reference file
+func (c *FilterCfg) SetTimeFilters(newer, older, changedWithin, accessedBefore string) *FilterCfg {
+	now := time.Now()
+	c.newer = c.parseTimeRef(newer, now)
+	c.older = c.parseTimeRef(older, now)
+	c.changedWithin = c.parseTimeRef(changedWithin, now)
+	c.accessedBefore = c.parseTimeRef(accessedBefore, now)
+	return c
+}
+
+// parseTimeRef
+func (c *FilterCfg) parseTimeRef(ref string, now time.Time) time.Time {
+	if ref == "" {
+		return time.Time{}
+	}
+	t, err := parseTimeRef(ref, now)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	return t
+}
+
+// SetOwnership set owner and group filters, by name or numeric id
+func (c *FilterCfg) SetOwnership(owner, group string) *FilterCfg {
+	if owner != "" {
+		uid, err := lookupUser(owner)
+		if err != nil {
+			log.Fatalf("invalid owner %s: %s", owner, err)
+		}
+		c.owner = &uid
+	}
+	if group != "" {
+		gid, err := lookupGroup(group)
+		if err != nil {
+			log.Fatalf("invalid group %s: %s", group, err)
+		}
+		c.group = &gid
+	}
+	return c
+}
+
+// SetPermissions set permission filters
+// perm is MODE for exact, -MODE for all of, /MODE for any of the bits, MODE is 0644 or u+x,o+w
+func (c *FilterCfg) SetPermissions(perm string, suid, executable, writableByMe bool) *FilterCfg {
+	if perm != "" {
+		rule, err := parsePerm(perm)
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+		c.perm = rule
+	}
+	c.suid = suid
+	c.executable = executable
+	c.writableByMe = writableByMe
+	return c
+}
+
+// SetKinds limit entry kinds of results: f,d,l,p,s,b,c, x for broken symlink
+func (c *FilterCfg) SetKinds(kinds string) *FilterCfg {
+	if kinds == "" {
+		return c
+	}
+	parsed, err := parseKinds(kinds)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	c.kinds = parsed
+	return c
+}
+
+// SetWhere set boolean filter expression, it is ANDed with the other filters
+// e.g. (glob '*.go' or size +1M) and not path 'testdata/**'
+func (c *FilterCfg) SetWhere(where string) *FilterCfg {
+	c.where = strings.TrimSpace(where)
+	return c
+}
+
+// SetListFiles only list paths of files with matches, or of files without match
+func (c *FilterCfg) SetListFiles(withMatches, withoutMatch bool) *FilterCfg {
+	if withMatches && withoutMatch {
+		log.Fatalf("--files-with-matches and --files-without-match can not be both set")
+	}
+	c.listFiles = withMatches || withoutMatch
+	c.withoutMatch = withoutMatch
+	return c
+}
+
+// SetMaxColumns lines longer than max columns bytes are output as a window around the match
+// 0 means no limit
+func (c *FilterCfg) SetMaxColumns(maxColumns int) *FilterCfg {
+	c.maxColumns = maxColumns
+	return c
+}
+
+// SetPathRules compile --exclude, --exclude-dir and --include rules
+// a rule with "/" is matched against the path relative to root, otherwise against the base name
+func (c *FilterCfg) SetPathRules(excludes, excludeDirs, includes []string) *FilterCfg {
+	c.excludes = c.compilePathRules(excludes)
+	c.excludeDirs = c.compilePathRules(excludeDirs)
+	c.includes = c.compilePathRules(includes)
+	return c
+}
+
+// compilePathRules
+func (c *FilterCfg) compilePathRules(patterns []string) []*yglob.Glob {
+	var rules []*yglob.Glob
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		g, err := yglob.Compile(strings.Trim(p, "/"), c.caseSensitive)
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+		rules = append(rules, g)
+	}
+	return rules
+}
+
+// setContentMatcher compile the content pattern only once
+func (c *FilterCfg) setContentMatcher() *FilterCfg {
+	if c.fileContent == "" {
+		return c
+	}
+	c.contentMatcher = newMatcher(c.fileContent, c.regex, c.caseSensitive, c.smartCase)
+	return c
+}
+
 ///// Filter /////
 func NewFilter(cfg *FilterCfg) *Filter {
 	f := Filter{
-		Cfg: cfg,
+		Cfg:   cfg,
+		Stats: youtput.NewStats(),
 	}
 	return f.init()
 }
@@ -121,40 +377,144 @@ func NewFilter(cfg *FilterCfg) *Filter {
 type filterFunc func(info os.FileInfo, xargs string) os.FileInfo
 
 type Filter struct {
-	Cfg        *FilterCfg
-	FilterFuns []filterFunc
+	Cfg   *FilterCfg
+	Stats *youtput.Stats
+	expr  exprNode
+	bound []*Filter // filters of --where predicates
+	// number of content predicates, by --content and in --where
+	contentNodes int
+	// --where has kind predicates, directories are not rejected by default
+	whereKinds bool
 }
 
-// init filter functions but not include filterFileContent
+// init build the expression tree: every flag filter and the content filter are ANDed, and then --where
 func (f *Filter) init() *Filter {
-	return f.addFilterFun(f.filterFileSizeGreater).
-		addFilterFun(f.filterFileSizeLess).
-		addFilterFun(f.filterFileType).
-		addFilterFun(f.filterFileName)
+	f.addFilterFun(f.filterKind, youtput.SkipKind).
+		addFilterFun(f.filterFileSizeGreater, youtput.SkipSize).
+		addFilterFun(f.filterFileSizeLess, youtput.SkipSize).
+		addFilterFun(f.filterFileType, youtput.SkipType).
+		addFilterFun(f.filterFileName, youtput.SkipName).
+		addFilterFun(f.filterFileGlob, youtput.SkipName).
+		addFilterFun(f.filterPathRules, youtput.SkipPath).
+		addFilterFun(f.filterNewer, youtput.SkipTime).
+		addFilterFun(f.filterOlder, youtput.SkipTime).
+		addFilterFun(f.filterChangedWithin, youtput.SkipTime).
+		addFilterFun(f.filterAccessedBefore, youtput.SkipTime).
+		addFilterFun(f.filterOwner, youtput.SkipOwner).
+		addFilterFun(f.filterGroup, youtput.SkipOwner).
+		addFilterFun(f.filterPerm, youtput.SkipPerm).
+		addFilterFun(f.filterSuid, youtput.SkipPerm).
+		addFilterFun(f.filterExecutable, youtput.SkipPerm).
+		addFilterFun(f.filterWritableByMe, youtput.SkipPerm)
+
+	var content exprNode
+	if f.Cfg.contentMatcher != nil {
+		content = &contentNode{f: f, matcher: f.Cfg.contentMatcher}
+	}
+	if f.Cfg.where != "" {
+		where, err := f.parseExpr(f.Cfg.where)
+		if err != nil {
+			log.Fatalf("invalid --where expression: %s", err)
+		}
+		if countContent(where) == 0 {
+			f.addExpr(where)
+		} else if content == nil {
+			content = where
+		} else {
+			content = newAndNode(content, where)
+		}
+	}
+
+	if f.Cfg.withoutMatch {
+		if content == nil {
+			log.Fatalf("--files-without-match needs --content or content in --where")
+		}
+		content = &noMatchNode{sub: content}
+	}
+	if content != nil {
+		f.addExpr(content)
+	}
+	f.contentNodes = countContent(f.expr)
+	return f
 }
 
-// DoFilter do filter
-// do all filters which register in init function
-// and then do filter content function
-func (f *Filter) DoFilter(file os.FileInfo, path string) (p bool, o youtput.FileItem) {
-	for _, fun := range f.FilterFuns {
-		if fun(file, path) == nil {
-			return
+// SkipDir check if the directory matches any --exclude-dir rule
+// the walker calls it before reading the directory so excluded trees are never read
+func (f *Filter) SkipDir(dir os.FileInfo, baseDir string) bool {
+	return f.matchPathRules(f.Cfg.excludeDirs, baseDir+dir.Name())
+}
+
+// SetRootPaths set the search roots of the filter and of --where predicates
+func (f *Filter) SetRootPaths(paths []string) *Filter {
+	f.Cfg.SetRootPaths(paths)
+	for _, b := range f.bound {
+		b.Cfg.SetRootPaths(paths)
+	}
+	return f
+}
+
+// rootOf root which the path comes from, the longest one if roots are nested
+// empty if path is not under any root
+func (f *Filter) rootOf(fullPath string) string {
+	root := ""
+	for _, r := range f.Cfg.roots {
+		if len(r) > len(root) && strings.HasPrefix(fullPath, r) {
+			root = r
 		}
 	}
+	return root
+}
 
-	// filter file content
-	cf, o := f.filterFileContent(file, path)
-	if cf == nil {
+// SearchesContent check if any content filter is set, by --content or in --where
+func (f *Filter) SearchesContent() bool {
+	return f.contentNodes > 0
+}
+
+// DoFilter do filter
+// evaluate the expression tree built in init function, cheap filters run before content search
+// content scan stops early when ctx is done, the matched lines found so far are kept
+func (f *Filter) DoFilter(ctx context.Context, file os.FileInfo, path string) (p bool, o youtput.FileItem) {
+	f.Stats.AddFile()
+	e := &evalEnv{ctx: ctx, file: file, baseDir: path}
+	if f.expr != nil && !f.expr.eval(e) {
+		f.Stats.AddSkip(e.reason)
 		return
 	}
 
-	return true, o
+	return true, f.matched(f.newFileItem(file, path), e)
+}
+
+// matched add the content search result to the item of a matched file and count it
+func (f *Filter) matched(o youtput.FileItem, e *evalEnv) youtput.FileItem {
+	o.Lines, o.Binary, o.BinaryOffset = e.content.Lines, e.content.Binary, e.content.BinaryOffset
+	o.BytesRead, o.Elapsed = e.content.BytesRead, e.content.Elapsed
+	f.Stats.AddMatch(countHits(o.Lines))
+	return o
+}
+
+// countHits number of hit lines and matches in them
+func countHits(lines []youtput.FileItemLine) (hitLines, matches int) {
+	for _, l := range lines {
+		if l.Hit {
+			hitLines++
+			matches += len(l.Matches)
+		}
+	}
+	return
 }
 
 // addFilterFun
-func (f *Filter) addFilterFun(fun filterFunc) *Filter {
-	f.FilterFuns = append(f.FilterFuns, fun)
+func (f *Filter) addFilterFun(fun filterFunc, reason string) *Filter {
+	return f.addExpr(&predNode{fun: fun, reason: reason})
+}
+
+// addExpr AND node with the expression tree
+func (f *Filter) addExpr(node exprNode) *Filter {
+	if f.expr == nil {
+		f.expr = node
+	} else {
+		f.expr = newAndNode(f.expr, node)
+	}
 	return f
 }
 
@@ -199,66 +559,233 @@ func (f *Filter) filterFileName(file os.FileInfo, baseDir string) os.FileInfo {
 		return file
 	}
 	fileFullPath := baseDir + file.Name()
-	fileNameFilter := f.Cfg.fileName
-	// TODO: case sensitive
-	// BUG: display all lowercase
-	/*
-		if !f.Cfg.caseSensitive {
-			fileFullPath = strings.ToLower(fileFullPath)
-			fileNameFilter = strings.ToLower(fileNameFilter)
-		}
-	*/
-
-	if strings.Contains(fileFullPath, fileNameFilter) {
+	if len(f.Cfg.nameMatcher.FindAll([]byte(fileFullPath))) > 0 {
 		return file
 	}
 
 	return nil
 }
 
-// filterFileContent
-func (f *Filter) filterFileContent(file os.FileInfo, baseDir string) (os.FileInfo, youtput.FileItem) {
+// filterFileGlob
+func (f *Filter) filterFileGlob(file os.FileInfo, baseDir string) os.FileInfo {
+	if f.Cfg.glob == nil {
+		return file
+	}
+	fileFullPath := baseDir + file.Name()
+	start := f.globSubjectStart(fileFullPath)
+	if f.Cfg.globMode == GlobModeAbs {
+		absPath, err := filepath.Abs(fileFullPath)
+		if err != nil {
+			return nil
+		}
+		fileFullPath = absPath
+	}
+	if f.Cfg.glob.Match(fileFullPath[start:]) {
+		return file
+	}
+	return nil
+}
+
+// filterPathRules
+func (f *Filter) filterPathRules(file os.FileInfo, baseDir string) os.FileInfo {
+	fileFullPath := baseDir + file.Name()
+	if f.matchPathRules(f.Cfg.excludes, fileFullPath) {
+		return nil
+	}
+	if len(f.Cfg.includes) > 0 && !f.matchPathRules(f.Cfg.includes, fileFullPath) {
+		return nil
+	}
+	return file
+}
+
+// matchPathRules check if path matches any of the rules
+func (f *Filter) matchPathRules(rules []*yglob.Glob, fullPath string) bool {
+	if len(rules) == 0 {
+		return false
+	}
+
+	base := fullPath[strings.LastIndex(fullPath, "/")+1:]
+	rel := strings.TrimPrefix(fullPath, f.rootOf(fullPath))
+	for _, rule := range rules {
+		subject := base
+		if strings.Contains(rule.Pattern, "/") {
+			subject = rel
+		}
+		if rule.Match(subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// globSubjectStart index in the full path where the glob subject begins
+// basename for base mode, path under root for rel mode, whole path for abs mode
+func (f *Filter) globSubjectStart(fileFullPath string) int {
+	mode := f.Cfg.globMode
+	if mode == "" {
+		mode = GlobModeBase
+		if strings.Contains(f.Cfg.glob.Pattern, "/") {
+			mode = GlobModeRel
+		}
+	}
+	switch mode {
+	case GlobModeBase:
+		return strings.LastIndex(fileFullPath, "/") + 1
+	case GlobModeRel:
+		return len(f.rootOf(fileFullPath))
+	}
+	return 0
+}
+
+// newFileItem result item of file, content lines are added by content search
+func (f *Filter) newFileItem(file os.FileInfo, baseDir string) youtput.FileItem {
 	output := youtput.FileItem{}
 	fileFullPath := baseDir + file.Name()
 	output.FileName = fileFullPath
 	output.FileSize = file.Size()
+	output.Kind = kindOf(file.Mode())
+	if output.Kind == youtput.KindSymlink {
+		output.LinkTarget, _ = os.Readlink(fileFullPath)
+		if _, err := os.Stat(fileFullPath); err != nil {
+			output.Kind = youtput.KindBrokenSymlink
+		}
+	}
+	if f.Cfg.nameMatcher != nil {
+		output.NameMatches = f.Cfg.nameMatcher.FindAll([]byte(fileFullPath))
+	}
+	if f.Cfg.glob != nil {
+		start := f.globSubjectStart(fileFullPath)
+		output.NameMatches = append(output.NameMatches, youtput.Match{Start: start, End: len(fileFullPath)})
+	}
+	return output
+}
 
-	if f.Cfg.fileContent == "" {
-		return file, output
+// searchFile search file content with m, the hit and context lines are added to output
+// reason is the skip reason when the file can not be searched
+func (f *Filter) searchFile(ctx context.Context, file os.FileInfo, m matcher, output *youtput.FileItem) (hit bool, reason string) {
+	// only regular files are opened, reading a fifo or a device may block forever or never end
+	mode := file.Mode()
+	if mode&os.ModeSymlink != 0 {
+		target, err := os.Stat(output.FileName)
+		if err != nil {
+			return false, youtput.SkipUnreadable
+		}
+		mode = target.Mode()
+	}
+	if !mode.IsRegular() {
+		return false, specialReason(mode)
 	}
 
-	rFile, err := os.Open(fileFullPath)
+	rFile, err := os.OpenFile(output.FileName, os.O_RDONLY|openNonblock, 0)
 	if err != nil {
-		// TODO: check if the file is text plain file and check have read permission
 		// TODO: if no permission then bring the error message to front
-	}
-	if rFile == nil {
-		//
+		return false, youtput.SkipUnreadable
 	}
 	defer rFile.Close()
+	// the entry may have been replaced since it was listed
+	if st, err := rFile.Stat(); err != nil {
+		return false, youtput.SkipUnreadable
+	} else if !st.Mode().IsRegular() {
+		return false, specialReason(st.Mode())
+	}
+
+	return f.searchReader(ctx, rFile, m, output, nil)
+}
+
+// specialReason skip reason of a file which is not regular
+// only fifo, socket and device are special, directories (also by symlink) have no content
+func specialReason(mode os.FileMode) string {
+	if mode&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice|os.ModeCharDevice) != 0 {
+		return youtput.SkipSpecial
+	}
+	return youtput.SkipNotRegular
+}
 
-	filterContentStr := f.Cfg.fileContent
-	filterContentByte := []byte(filterContentStr)
-	var lineNum int64
-
-	scanner := bufio.NewScanner(rFile)
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		lineNum++
-		//content := scanner.Text()
-		content := scanner.Bytes()
-		// TODO: case sensitive
-		// BUG: display all lowercase
-		if bytes.Contains(content, filterContentByte) {
-			//if strings.Contains(content, f.Cfg.fileContent) {
-			lineItem := youtput.FileItemLine{Line: lineNum, Content: string(content), Hit: true}
-			output.Lines = append(output.Lines, lineItem)
+// searchReader search the content of r with m, the hit and context lines are added to output
+func (f *Filter) searchReader(ctx context.Context, r io.Reader, m matcher, output *youtput.FileItem, flush func([]youtput.FileItemLine)) (hit bool, reason string) {
+	start := time.Now()
+	reader := &countReader{r: r}
+	defer func() { f.Stats.AddBytesRead(reader.n) }()
+
+	// flushed lines are not in output any more, but they are hits
+	flushed := false
+	if flush != nil {
+		emit := flush
+		flush = func(lines []youtput.FileItemLine) {
+			flushed = true
+			emit(lines)
 		}
 	}
+	if skipBinary := f.searchContent(ctx, reader, m, output, flush); skipBinary {
+		return false, youtput.SkipBinary
+	}
 
-	if len(output.Lines) > 0 {
-		return file, output
+	hit = f.contentResult(output, reader.n, start) || flushed
+	if !hit && ctx.Err() != nil {
+		// the rest of the content is unknown, so no hit is not a result either
+		return false, youtput.SkipCancelled
+	}
+	return hit, ""
+}
+
+// lineRing ring buffer of the latest lines
+type lineRing struct {
+	lines []youtput.FileItemLine
+	size  int
+	next  int
+	count int
+}
+
+// newLineRing
+func newLineRing(size int) *lineRing {
+	return &lineRing{lines: make([]youtput.FileItemLine, size), size: size}
+}
+
+// push add line, the oldest line is dropped when full
+func (r *lineRing) push(line youtput.FileItemLine) {
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % r.size
+	if r.count < r.size {
+		r.count++
 	}
+}
 
-	return nil, output
+// drain return lines from the oldest and empty the ring
+func (r *lineRing) drain() []youtput.FileItemLine {
+	if r.count == 0 {
+		return nil
+	}
+	lines := make([]youtput.FileItemLine, 0, r.count)
+	for i := r.count; i > 0; i-- {
+		lines = append(lines, r.lines[(r.next-i+r.size)%r.size])
+	}
+	r.count = 0
+	return lines
+}
+
+// countReader count bytes read from r
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// contentResult file passes content filter if any line is hit
+// lines of binary file are dropped, only "binary file matches" is reported
+func (f *Filter) contentResult(output *youtput.FileItem, bytesRead int64, start time.Time) bool {
+	output.BytesRead += bytesRead
+	output.Elapsed += time.Since(start)
+	if len(output.Lines) > 0 {
+		if output.Binary {
+			output.Lines = nil
+		}
+		return true
+	}
+	return false
 }
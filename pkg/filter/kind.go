@@ -0,0 +1,80 @@
+package yfilter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	youtput "github.com/fhquthpdw/yfind/pkg/output"
+)
+
+// kindOf entry kind of file mode
+func kindOf(mode os.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return youtput.KindFile
+	case mode.IsDir():
+		return youtput.KindDir
+	case mode&os.ModeSymlink != 0:
+		return youtput.KindSymlink
+	case mode&os.ModeNamedPipe != 0:
+		return youtput.KindPipe
+	case mode&os.ModeSocket != 0:
+		return youtput.KindSocket
+	case mode&os.ModeCharDevice != 0:
+		return youtput.KindCharDevice
+	case mode&os.ModeDevice != 0:
+		return youtput.KindBlockDevice
+	}
+	return youtput.KindOther
+}
+
+// parseKinds parse comma separated kinds: f,d,l,p,s,b,c and x for broken symlink
+func parseKinds(kinds string) (map[string]struct{}, error) {
+	valid := map[string]struct{}{
+		youtput.KindFile: {}, youtput.KindDir: {}, youtput.KindSymlink: {}, youtput.KindPipe: {},
+		youtput.KindSocket: {}, youtput.KindBlockDevice: {}, youtput.KindCharDevice: {}, youtput.KindBrokenSymlink: {},
+	}
+
+	parsed := make(map[string]struct{})
+	for _, k := range strings.Split(strings.ReplaceAll(kinds, " ", ""), ",") {
+		if k == "" {
+			continue
+		}
+		if _, ok := valid[k]; !ok {
+			return nil, fmt.Errorf("invalid kind: %s", k)
+		}
+		parsed[k] = struct{}{}
+	}
+	return parsed, nil
+}
+
+// WantDirs check if directories can be results, by --kind or by kind in --where
+func (f *Filter) WantDirs() bool {
+	_, ok := f.Cfg.kinds[youtput.KindDir]
+	return ok || f.whereKinds
+}
+
+// filterKind
+// without --kind, every entry except directories passes, unless --where decides the kind
+func (f *Filter) filterKind(file os.FileInfo, baseDir string) os.FileInfo {
+	kind := kindOf(file.Mode())
+	if f.Cfg.kinds == nil {
+		if kind == youtput.KindDir && !f.whereKinds {
+			return nil
+		}
+		return file
+	}
+
+	if _, ok := f.Cfg.kinds[kind]; ok {
+		return file
+	}
+	if kind == youtput.KindSymlink {
+		if _, ok := f.Cfg.kinds[youtput.KindBrokenSymlink]; ok {
+			if _, err := os.Stat(baseDir + file.Name()); err != nil {
+				return file
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package yfilter
+
+import (
+	"unicode/utf8"
+
+	youtput "github.com/fhquthpdw/yfind/pkg/output"
+)
+
+// newFileItemLine build output line
+// line longer than max columns is trimmed to a window around its first match
+func (f *Filter) newFileItemLine(lineNum, offset int64, content []byte, matches []youtput.Match) youtput.FileItemLine {
+	item := youtput.FileItemLine{Line: lineNum, Offset: offset, Hit: len(matches) > 0}
+
+	maxColumns := f.Cfg.maxColumns
+	if maxColumns <= 0 || len(content) <= maxColumns {
+		item.Content = string(content)
+		item.Matches = matches
+		return item
+	}
+
+	// window [start, end) of max columns bytes, the first match in the middle
+	start := 0
+	if len(matches) > 0 {
+		m := matches[0]
+		start = m.Start - (maxColumns-(m.End-m.Start))/2
+		if start > m.Start {
+			start = m.Start
+		}
+		if start > len(content)-maxColumns {
+			start = len(content) - maxColumns
+		}
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + maxColumns
+	// do not cut runes
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end--
+	}
+
+	for _, m := range matches {
+		if m.End <= start || m.Start >= end {
+			continue
+		}
+		if m.Start < start {
+			m.Start = start
+		}
+		if m.End > end {
+			m.End = end
+		}
+		item.Matches = append(item.Matches, youtput.Match{Start: m.Start - start, End: m.End - start})
+	}
+	item.Content = string(content[start:end])
+	item.TrimmedLeft = start
+	item.TrimmedRight = len(content) - end
+	return item
+}
@@ -0,0 +1,269 @@
+package yfilter
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+	"regexp/syntax"
+	"unicode"
+	"unicode/utf8"
+
+	youtput "github.com/fhquthpdw/yfind/pkg/output"
+)
+
+// matcher find all matches of a name or content filter in one line
+// Find locates the first candidate in a buffer of many lines, FindFrom takes it as the first match
+// of the candidate line and only searches the line after it
+type matcher interface {
+	Find(buf []byte) (start, end int)
+	FindAll(line []byte) []youtput.Match
+	FindFrom(line []byte, start, end int) []youtput.Match
+}
+
+// newMatcher
+// literal matcher by default, RE2 matcher if regex is set.
+// case insensitive matching always goes through RE2 with (?i),
+// so that unicode case folding is used and the original text is kept for display
+func newMatcher(pattern string, regex, caseSensitive, smartCase bool) matcher {
+	if smartCase {
+		caseSensitive = hasUppercase(pattern, regex)
+	}
+
+	if !regex {
+		if caseSensitive {
+			return &literalMatcher{literal: []byte(pattern)}
+		}
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Fatalf("invalid regex: %s", err)
+	}
+	reBuf, lookBehind := bufRegexp(pattern)
+	return &regexMatcher{re: re, reBuf: reBuf, lookBehind: lookBehind}
+}
+
+// bufRegexp compile pattern for searching a buffer of many lines
+// anchors of text are rewritten to anchors of line, and "$" also matches before "\r\n",
+// so every match in a line (whose line terminator is dropped) is also a match in the buffer.
+// nothing in it matches "\n", a match never crosses lines, otherwise patterns like [^x]*
+// would scan the rest of the buffer again for every rejected candidate line
+func bufRegexp(pattern string) (reBuf *regexp.Regexp, lookBehind bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		log.Fatalf("invalid regex: %s", err)
+	}
+	re = lineAnchors(re)
+	return regexp.MustCompile(re.String()), looksBehind(re)
+}
+
+// looksBehind check if re has assertions on the text before the position,
+// such a regex can not go on matching in a line sliced after the previous match
+func looksBehind(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginLine, syntax.OpBeginText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if looksBehind(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineAnchors
+func lineAnchors(re *syntax.Regexp) *syntax.Regexp {
+	switch re.Op {
+	case syntax.OpAnyChar:
+		return &syntax.Regexp{Op: syntax.OpAnyCharNotNL}
+	case syntax.OpCharClass:
+		re.Rune = withoutNewline(re.Rune)
+		if len(re.Rune) == 0 {
+			return &syntax.Regexp{Op: syntax.OpNoMatch}
+		}
+		return re
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '\n' {
+				// a line never has "\n"
+				return &syntax.Regexp{Op: syntax.OpNoMatch}
+			}
+		}
+		return re
+	case syntax.OpBeginText:
+		return &syntax.Regexp{Op: syntax.OpBeginLine}
+	case syntax.OpEndText, syntax.OpEndLine:
+		cr := &syntax.Regexp{Op: syntax.OpLiteral, Rune: []rune{'\r'}}
+		return &syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{
+			{Op: syntax.OpQuest, Sub: []*syntax.Regexp{cr}},
+			{Op: syntax.OpEndLine},
+		}}
+	}
+	for i, sub := range re.Sub {
+		re.Sub[i] = lineAnchors(sub)
+	}
+	return re
+}
+
+// withoutNewline remove "\n" from the ranges of a char class
+func withoutNewline(ranges []rune) []rune {
+	out := make([]rune, 0, len(ranges)+2)
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo > '\n' || hi < '\n' {
+			out = append(out, lo, hi)
+			continue
+		}
+		if lo < '\n' {
+			out = append(out, lo, '\n'-1)
+		}
+		if hi > '\n' {
+			out = append(out, '\n'+1, hi)
+		}
+	}
+	return out
+}
+
+// hasUppercase check if pattern has any uppercase letter
+// escape sequences like \W or \S in regex pattern are not counted
+func hasUppercase(pattern string, regex bool) bool {
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+		if regex && r == '\\' {
+			_, size = utf8.DecodeRuneInString(pattern[i:])
+			i += size
+			continue
+		}
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+///// Literal Matcher /////
+
+type literalMatcher struct {
+	literal []byte
+}
+
+// Find
+func (m *literalMatcher) Find(buf []byte) (start, end int) {
+	if len(m.literal) == 0 {
+		return -1, -1
+	}
+	start = bytes.Index(buf, m.literal)
+	if start < 0 {
+		return -1, -1
+	}
+	return start, start + len(m.literal)
+}
+
+// FindAll
+func (m *literalMatcher) FindAll(line []byte) []youtput.Match {
+	return m.appendAfter(nil, line, 0)
+}
+
+// FindFrom
+// a match into the dropped "\r" or across lines is not in the line, the whole line is searched again
+func (m *literalMatcher) FindFrom(line []byte, start, end int) []youtput.Match {
+	if end > len(line) {
+		return m.FindAll(line)
+	}
+	return m.appendAfter([]youtput.Match{{Start: start, End: end}}, line, end)
+}
+
+// appendAfter append matches in line from offset
+func (m *literalMatcher) appendAfter(matches []youtput.Match, line []byte, offset int) []youtput.Match {
+	if len(m.literal) == 0 {
+		return matches
+	}
+
+	for {
+		idx := bytes.Index(line[offset:], m.literal)
+		if idx < 0 {
+			return matches
+		}
+		start := offset + idx
+		end := start + len(m.literal)
+		matches = append(matches, youtput.Match{Start: start, End: end})
+		offset = end
+	}
+}
+
+///// Regex Matcher /////
+
+type regexMatcher struct {
+	re         *regexp.Regexp
+	reBuf      *regexp.Regexp
+	lookBehind bool // reBuf can not be run on a line sliced after a match, see looksBehind
+}
+
+// findWindow bytes of buffer searched by one regex call, regexp only uses its fast backtracker on small inputs
+const findWindow = 4 * 1024
+
+// Find
+// a match never crosses lines, but it may end in the "\r" of "\r\n", so it is only a candidate.
+// no line matches before the first candidate line.
+// the buffer is searched in windows of whole lines, a match never crosses them either
+func (m *regexMatcher) Find(buf []byte) (start, end int) {
+	for base := 0; base < len(buf); {
+		limit := len(buf)
+		if base+findWindow < limit {
+			if nl := bytes.LastIndexByte(buf[base:base+findWindow], '\n'); nl >= 0 {
+				limit = base + nl + 1
+			} else if nl = bytes.IndexByte(buf[base+findWindow:], '\n'); nl >= 0 {
+				limit = base + findWindow + nl + 1
+			}
+		}
+
+		// an empty match at the end of a window is at the start of the next line, it is searched with that line
+		if loc := m.reBuf.FindIndex(buf[base:limit]); loc != nil && (base+loc[0] < limit || limit == len(buf)) {
+			return base + loc[0], base + loc[1]
+		}
+		base = limit
+	}
+	return -1, -1
+}
+
+// FindFrom
+// reBuf matches the same as re in a line, so only the rest of the line after the first match is searched.
+// a match into the dropped "\r", or assertions on the text before, need the whole line searched again
+func (m *regexMatcher) FindFrom(line []byte, start, end int) (matches []youtput.Match) {
+	if end > len(line) || m.lookBehind {
+		return m.FindAll(line)
+	}
+
+	matches = append(matches, youtput.Match{Start: start, End: end})
+	from := end
+	if start == end {
+		// after an empty match the search goes on after the next rune, the same as FindAll
+		if end == len(line) {
+			return
+		}
+		_, size := utf8.DecodeRune(line[end:])
+		from += size
+	}
+	for _, loc := range m.reBuf.FindAllIndex(line[from:], -1) {
+		if loc[1] == 0 && from == end {
+			// an empty match right after the previous match is not a match
+			continue
+		}
+		matches = append(matches, youtput.Match{Start: from + loc[0], End: from + loc[1]})
+	}
+	return
+}
+
+// FindAll
+func (m *regexMatcher) FindAll(line []byte) (matches []youtput.Match) {
+	for _, loc := range m.re.FindAllIndex(line, -1) {
+		matches = append(matches, youtput.Match{Start: loc[0], End: loc[1]})
+	}
+	return
+}
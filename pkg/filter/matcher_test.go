@@ -0,0 +1,48 @@
+package yfilter
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestFindFromMatchesFindAll the matches of a line taken from the buffer search are the matches of the line
+func TestFindFromMatchesFindAll(t *testing.T) {
+	patterns := []struct {
+		pattern string
+		regex   bool
+	}{
+		{"foo", false},
+		{"oo", false},
+		{"a\r", false},
+		{`fo+`, true},
+		{`o*`, true},
+		{`x*`, true},
+		{`a.`, true},
+		{`o$`, true},
+		{`^f`, true},
+		{`\bfoo`, true},
+		{`foo\s*`, true},
+		{`é|o`, true},
+		{`(?i)FOO`, true},
+	}
+	lines := []string{"foo", "foofoo foo", "", "a", "xfoox", "é foo é", "booo oo", "a foo\t"}
+
+	for _, p := range patterns {
+		m := newMatcher(p.pattern, p.regex, true, false)
+		for _, line := range lines {
+			for _, eol := range []string{"\n", "\r\n"} {
+				want := fmt.Sprint(m.FindAll([]byte(line)))
+				start, end := m.Find([]byte(line + eol))
+				if start < 0 {
+					if want != "[]" {
+						t.Errorf("%q in %q: got no candidate, want %s", p.pattern, line+eol, want)
+					}
+					continue
+				}
+				if got := fmt.Sprint(m.FindFrom([]byte(line), start, end)); got != want {
+					t.Errorf("%q in %q: got %s, want %s", p.pattern, line+eol, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package yfilter
+
+// openNonblock no non-blocking open on this platform
+const openNonblock = 0
@@ -0,0 +1,9 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package yfilter
+
+import "syscall"
+
+// openNonblock open flag so that a special file swapped in after lstat can not block the worker
+const openNonblock = syscall.O_NONBLOCK
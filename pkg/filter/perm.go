@@ -0,0 +1,210 @@
+package yfilter
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// perm match kinds, like the prefixes of find -perm
+const (
+	permExact = iota // MODE   all permission bits are exactly mode
+	permAllOf        // -MODE  all bits of mode are set
+	permAnyOf        // /MODE  any bit of mode is set
+)
+
+// permRule
+type permRule struct {
+	mode uint32
+	kind int
+}
+
+// parsePerm parse --perm value
+// mode is octal like 0644, or symbolic like u+x,g+w,o=r
+func parsePerm(s string) (*permRule, error) {
+	rule := &permRule{kind: permExact}
+	switch {
+	case strings.HasPrefix(s, "-"):
+		rule.kind = permAllOf
+		s = s[1:]
+	case strings.HasPrefix(s, "/"):
+		rule.kind = permAnyOf
+		s = s[1:]
+	}
+
+	if n, err := strconv.ParseUint(s, 8, 32); err == nil {
+		if n > 07777 {
+			return nil, fmt.Errorf("invalid perm %q", s)
+		}
+		rule.mode = uint32(n)
+		return rule, nil
+	}
+
+	mode, err := parseSymbolicMode(s)
+	if err != nil {
+		return nil, err
+	}
+	rule.mode = mode
+	return rule, nil
+}
+
+// parseSymbolicMode parse comma separated clauses like u+x, go=r, a+rwx, u+s
+func parseSymbolicMode(s string) (uint32, error) {
+	var mode uint32
+	for _, clause := range strings.Split(s, ",") {
+		opIdx := strings.IndexAny(clause, "+=")
+		if opIdx < 0 {
+			return 0, fmt.Errorf("invalid perm %q", s)
+		}
+
+		who := clause[:opIdx]
+		if who == "" {
+			who = "a"
+		}
+		var whoMask uint32
+		for _, c := range who {
+			switch c {
+			case 'u':
+				whoMask |= 04700
+			case 'g':
+				whoMask |= 02070
+			case 'o':
+				whoMask |= 01007
+			case 'a':
+				whoMask |= 07777
+			default:
+				return 0, fmt.Errorf("invalid perm %q", s)
+			}
+		}
+
+		var permBits uint32
+		for _, c := range clause[opIdx+1:] {
+			switch c {
+			case 'r':
+				permBits |= 0444
+			case 'w':
+				permBits |= 0222
+			case 'x':
+				permBits |= 0111
+			case 's':
+				permBits |= 06000
+			case 't':
+				permBits |= 01000
+			default:
+				return 0, fmt.Errorf("invalid perm %q", s)
+			}
+		}
+		mode |= whoMask & permBits
+	}
+	return mode, nil
+}
+
+// unixMode permission bits of file mode in unix layout
+func unixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// match
+func (r *permRule) match(mode os.FileMode) bool {
+	m := unixMode(mode)
+	switch r.kind {
+	case permAllOf:
+		return m&r.mode == r.mode
+	case permAnyOf:
+		// find -perm /000 matches everything
+		return r.mode == 0 || m&r.mode != 0
+	}
+	return m == r.mode
+}
+
+// lookupUser user name or numeric uid to uid
+func lookupUser(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(u.Uid, 10, 32)
+	return uint32(id), err
+}
+
+// lookupGroup group name or numeric gid to gid
+func lookupGroup(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(g.Gid, 10, 32)
+	return uint32(id), err
+}
+
+// filterOwner
+func (f *Filter) filterOwner(file os.FileInfo, _ string) os.FileInfo {
+	if f.Cfg.owner == nil {
+		return file
+	}
+	if uid, _, ok := fileOwner(file); ok && uid == *f.Cfg.owner {
+		return file
+	}
+	return nil
+}
+
+// filterGroup
+func (f *Filter) filterGroup(file os.FileInfo, _ string) os.FileInfo {
+	if f.Cfg.group == nil {
+		return file
+	}
+	if _, gid, ok := fileOwner(file); ok && gid == *f.Cfg.group {
+		return file
+	}
+	return nil
+}
+
+// filterPerm
+func (f *Filter) filterPerm(file os.FileInfo, _ string) os.FileInfo {
+	if f.Cfg.perm == nil || f.Cfg.perm.match(file.Mode()) {
+		return file
+	}
+	return nil
+}
+
+// filterSuid setuid bit is set
+func (f *Filter) filterSuid(file os.FileInfo, _ string) os.FileInfo {
+	if !f.Cfg.suid || file.Mode()&os.ModeSetuid != 0 {
+		return file
+	}
+	return nil
+}
+
+// filterExecutable executable by the current user
+func (f *Filter) filterExecutable(file os.FileInfo, baseDir string) os.FileInfo {
+	if !f.Cfg.executable || canAccess(baseDir+file.Name(), accessExecute) {
+		return file
+	}
+	return nil
+}
+
+// filterWritableByMe writable by the current user
+func (f *Filter) filterWritableByMe(file os.FileInfo, baseDir string) os.FileInfo {
+	if !f.Cfg.writableByMe || canAccess(baseDir+file.Name(), accessWrite) {
+		return file
+	}
+	return nil
+}
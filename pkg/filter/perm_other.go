@@ -0,0 +1,29 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package yfilter
+
+import (
+	"os"
+)
+
+// access modes of canAccess
+const (
+	accessWrite   = 0x2
+	accessExecute = 0x1
+)
+
+// fileOwner owner is not available
+func fileOwner(_ os.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
+
+// canAccess guess access by permission bits
+func canAccess(path string, mode uint32) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	perm := uint32(info.Mode().Perm())
+	return perm&(mode<<6) != 0
+}
@@ -0,0 +1,29 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package yfilter
+
+import (
+	"os"
+	"syscall"
+)
+
+// access modes of canAccess
+const (
+	accessWrite   = 0x2 // W_OK
+	accessExecute = 0x1 // X_OK
+)
+
+// fileOwner uid and gid of file
+func fileOwner(file os.FileInfo) (uid, gid uint32, ok bool) {
+	st, ok := file.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return st.Uid, st.Gid, true
+}
+
+// canAccess check access of the current user with access(2)
+func canAccess(path string, mode uint32) bool {
+	return syscall.Access(path, mode) == nil
+}
@@ -0,0 +1,53 @@
+package yfilter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	youtput "github.com/fhquthpdw/yfind/pkg/output"
+)
+
+// DoFilterReader do filter on a stream like stdin, info.Name() is the label of the result
+// content predicates search r instead of opening a file, the other filters check info.
+// r is streamed if there is only one content predicate, otherwise it is read into memory first.
+// if emit is set and the content predicate decides alone, hit lines are emitted in batches as they are found,
+// the returned result then continues the emitted ones, see FileItem.Partial
+func (f *Filter) DoFilterReader(ctx context.Context, r io.Reader, info os.FileInfo, emit func(youtput.FileItem)) (p bool, o youtput.FileItem) {
+	f.Stats.AddFile()
+	open := func() io.Reader { return r }
+	size := int64(-1) // bytes read by the content predicate, if the stream is not read into memory
+	if f.contentNodes > 1 {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			log.Printf("%s: %s\n", info.Name(), err)
+		}
+		open = func() io.Reader { return bytes.NewReader(data) }
+		size = int64(len(data))
+	}
+
+	e := &evalEnv{ctx: ctx, file: info, reader: open}
+	continued := false
+	if emit != nil && streamsContent(f.expr) {
+		e.flush = func(lines []youtput.FileItemLine) {
+			f.Stats.AddLines(countHits(lines))
+			emit(youtput.FileItem{FileName: info.Name(), Lines: lines, Partial: true, Continued: continued})
+			continued = true
+		}
+	}
+	if f.expr != nil && !f.expr.eval(e) {
+		f.Stats.AddSkip(e.reason)
+		return
+	}
+
+	o = f.matched(youtput.FileItem{FileName: info.Name()}, e)
+	o.FileSize = size
+	if size < 0 {
+		o.FileSize = o.BytesRead
+	}
+	o.Continued = continued
+	return true, o
+}
@@ -0,0 +1,236 @@
+package yfilter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"sync"
+
+	youtput "github.com/fhquthpdw/yfind/pkg/output"
+)
+
+// searchBufSize size of read buffer, a line longer than it grows the buffer
+const searchBufSize = 256 * 1024
+
+// searchBufPool read buffers reused across files
+var searchBufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, searchBufSize)
+		return &buf
+	},
+}
+
+// contentSearch search the content of one file
+// the file is read in big chunks of complete lines, the matcher runs on the whole chunk,
+// line boundaries are only located around the hits, so chunks without hit never pay per line costs.
+// context lines are also only collected around the hits
+type contentSearch struct {
+	f         *Filter
+	matcher   matcher
+	output    *youtput.FileItem
+	before    *lineRing // lines before the next hit, kept for before context
+	afterLeft int       // lines left to output as after context of the last hit
+	lineNum   int64     // number of lines before chunk[counted]
+	counted   int       // newlines before it in the current chunk are counted in lineNum
+	offset    int64     // file offset of the current chunk
+}
+
+// searchContent search r with m, append the hit and context lines to output
+// if flush is set, r is a stream: it is read as data comes and the lines are flushed after every chunk
+// return true if the file is skipped as binary
+func (f *Filter) searchContent(ctx context.Context, r io.Reader, m matcher, output *youtput.FileItem, flush func([]youtput.FileItemLine)) (skipBinary bool) {
+	bufPtr := searchBufPool.Get().(*[]byte)
+	defer searchBufPool.Put(bufPtr)
+	buf := *bufPtr
+
+	s := &contentSearch{f: f, matcher: m, output: output, before: newLineRing(f.Cfg.beforeContext)}
+
+	data := 0 // bytes of data in buf
+	eof := false
+	sniffed := false
+	done := ctx.Done()
+	for !eof || data > 0 {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+
+		if !eof {
+			n, err := readChunk(r, buf[data:], flush != nil)
+			data += n
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				// keep the lines found before the error
+				log.Printf("%s: %s\n", output.FileName, err)
+				return false
+			}
+		}
+
+		// sniff the first block for binary content
+		if !sniffed {
+			sniffed = true
+			if f.Cfg.binaryMode != BinaryText {
+				head := buf[:data]
+				if len(head) > binarySniffSize {
+					head = head[:binarySniffSize]
+				}
+				output.Binary, output.BinaryOffset = sniffBinary(head)
+				if output.Binary && f.Cfg.binaryMode == BinarySkip {
+					return true
+				}
+			}
+		}
+
+		// search complete lines only, the incomplete last line waits for more data
+		limit := data
+		if !eof {
+			limit = bytes.LastIndexByte(buf[:data], '\n') + 1
+			if limit == 0 {
+				if data == len(buf) {
+					// the line is longer than buffer
+					grown := make([]byte, len(buf)*2)
+					copy(grown, buf[:data])
+					buf = grown
+				}
+				continue
+			}
+		}
+
+		if stop := s.searchChunk(buf[:limit]); stop {
+			return false
+		}
+		if flush != nil && len(output.Lines) > 0 && !output.Binary {
+			flush(output.Lines)
+			output.Lines = nil
+		}
+
+		data = copy(buf, buf[limit:data])
+		s.offset += int64(limit)
+		s.counted = 0
+	}
+	return false
+}
+
+// readChunk fill buf from r, err is io.EOF at the end of r
+// a stream returns what is available, so its hits are not held back until the buffer is full
+func readChunk(r io.Reader, buf []byte, stream bool) (int, error) {
+	if stream {
+		return r.Read(buf)
+	}
+	n, err := io.ReadFull(r, buf)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// searchChunk search chunk of complete lines
+// return true when no more search is needed
+func (s *contentSearch) searchChunk(chunk []byte) (stop bool) {
+	pos := 0 // start of the lines not searched yet
+	for pos < len(chunk) {
+		start, end := s.matcher.Find(chunk[pos:])
+		if start < 0 {
+			break
+		}
+
+		lineStart := pos + bytes.LastIndexByte(chunk[pos:pos+start], '\n') + 1
+		if lineStart == len(chunk) {
+			// empty match after the last line terminator, there is no line
+			break
+		}
+		lineEnd, next := nextLine(chunk, lineStart)
+		s.gap(chunk, pos, lineStart)
+		if stop := s.candidateLine(chunk, lineStart, lineEnd, pos+start-lineStart, pos+end-lineStart); stop {
+			return true
+		}
+		pos = next
+	}
+
+	s.gap(chunk, pos, len(chunk))
+	s.countLines(chunk, len(chunk))
+	return false
+}
+
+// candidateLine check the line where the matcher found a candidate at [start, end) of the line
+func (s *contentSearch) candidateLine(chunk []byte, lineStart, lineEnd, start, end int) (stop bool) {
+	s.countLines(chunk, lineStart)
+	content := trimCR(chunk[lineStart:lineEnd])
+
+	matches := s.matcher.FindFrom(content, start, end)
+	if len(matches) == 0 {
+		// candidate crossing the line end, not a hit
+		s.contextLine(lineStart, content)
+		return false
+	}
+
+	s.output.Lines = append(s.output.Lines, s.before.drain()...)
+	s.output.Lines = append(s.output.Lines, s.f.newFileItemLine(s.lineNum+1, s.offset+int64(lineStart), content, matches))
+	s.afterLeft = s.f.Cfg.afterContext
+	// binary report mode and listing files only need to know if there is a match
+	return s.output.Binary || s.f.Cfg.listFiles
+}
+
+// gap handle lines [from, to) between hits, they are only needed for context
+func (s *contentSearch) gap(chunk []byte, from, to int) {
+	for from < to && s.afterLeft > 0 {
+		lineEnd, next := nextLine(chunk[:to], from)
+		s.countLines(chunk, from)
+		s.contextLine(from, trimCR(chunk[from:lineEnd]))
+		from = next
+	}
+	if s.before.size == 0 || from >= to {
+		return
+	}
+
+	// only the last lines of the gap can be before context
+	start := to
+	for i := 0; i < s.before.size && start > from; i++ {
+		start = from + bytes.LastIndexByte(chunk[from:start-1], '\n') + 1
+	}
+	for start < to {
+		lineEnd, next := nextLine(chunk[:to], start)
+		s.countLines(chunk, start)
+		s.contextLine(start, trimCR(chunk[start:lineEnd]))
+		start = next
+	}
+}
+
+// contextLine non hit line, output as after context or kept for before context
+func (s *contentSearch) contextLine(lineStart int, content []byte) {
+	if s.afterLeft > 0 {
+		s.output.Lines = append(s.output.Lines, s.f.newFileItemLine(s.lineNum+1, s.offset+int64(lineStart), content, nil))
+		s.afterLeft--
+	} else if s.before.size > 0 {
+		s.before.push(s.f.newFileItemLine(s.lineNum+1, s.offset+int64(lineStart), content, nil))
+	}
+}
+
+// countLines count lines up to chunk[to]
+func (s *contentSearch) countLines(chunk []byte, to int) {
+	if to <= s.counted {
+		return
+	}
+	s.lineNum += int64(bytes.Count(chunk[s.counted:to], []byte{'\n'}))
+	s.counted = to
+}
+
+// nextLine return the end of line starting at start without "\n", and the start of next line
+func nextLine(chunk []byte, start int) (lineEnd, next int) {
+	idx := bytes.IndexByte(chunk[start:], '\n')
+	if idx < 0 {
+		return len(chunk), len(chunk)
+	}
+	return start + idx, start + idx + 1
+}
+
+// trimCR drop "\r" of "\r\n" line terminator
+func trimCR(line []byte) []byte {
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		return line[:len(line)-1]
+	}
+	return line
+}
@@ -0,0 +1,423 @@
+package yfilter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	youtput "github.com/fhquthpdw/yfind/pkg/output"
+)
+
+// newTestFilter content filter without binary detection
+func newTestFilter(pattern string, regex bool, before, after int) *Filter {
+	cfg := NewFilterCfg("", "", "", "", pattern, true, false, regex).
+		SetContext(before, after, 0).
+		SetBinaryMode(BinaryText)
+	return NewFilter(cfg)
+}
+
+// searchChunks feed chunks of complete lines to searchChunk, the same as searchContent does
+func searchChunks(f *Filter, chunks []string) []youtput.FileItemLine {
+	var output youtput.FileItem
+	s := &contentSearch{f: f, matcher: f.Cfg.contentMatcher, output: &output, before: newLineRing(f.Cfg.beforeContext)}
+	for _, chunk := range chunks {
+		if stop := s.searchChunk([]byte(chunk)); stop {
+			break
+		}
+		s.offset += int64(len(chunk))
+		s.counted = 0
+	}
+	return output.Lines
+}
+
+// lineScan line by line scanner, how content was searched before the chunked search
+// it is the reference of the results and the baseline of the benchmarks
+func lineScan(f *Filter, r io.Reader) []youtput.FileItemLine {
+	var lines []youtput.FileItemLine
+	before := newLineRing(f.Cfg.beforeContext)
+	afterLeft := 0
+	var lineNum, offset int64
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	var long []byte
+	for {
+		line, err := br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			long = append(long[:0], line...)
+			for err == bufio.ErrBufferFull {
+				line, err = br.ReadSlice('\n')
+				long = append(long, line...)
+			}
+			line = long
+		}
+		if len(line) == 0 {
+			return lines
+		}
+
+		advance := len(line)
+		content := trimCR(bytes.TrimSuffix(line, []byte{'\n'}))
+		lineNum++
+		if matches := f.Cfg.contentMatcher.FindAll(content); len(matches) > 0 {
+			lines = append(lines, before.drain()...)
+			lines = append(lines, f.newFileItemLine(lineNum, offset, content, matches))
+			afterLeft = f.Cfg.afterContext
+		} else if afterLeft > 0 {
+			lines = append(lines, f.newFileItemLine(lineNum, offset, content, nil))
+			afterLeft--
+		} else if before.size > 0 {
+			before.push(f.newFileItemLine(lineNum, offset, content, nil))
+		}
+		offset += int64(advance)
+		if err != nil {
+			return lines
+		}
+	}
+}
+
+// searchAll search data with searchContent
+func searchAll(f *Filter, data []byte) []youtput.FileItemLine {
+	var output youtput.FileItem
+	f.searchContent(context.Background(), bytes.NewReader(data), f.Cfg.contentMatcher, &output, nil)
+	return output.Lines
+}
+
+type wantLine struct {
+	line    int64
+	offset  int64
+	content string
+	hit     bool
+	matches int
+}
+
+func TestSearchChunk(t *testing.T) {
+	tests := []struct {
+		name          string
+		pattern       string
+		regex         bool
+		before, after int
+		chunks        []string
+		want          []wantLine
+	}{
+		{
+			name:    "hit in second chunk",
+			pattern: "foo",
+			chunks:  []string{"a\nb\n", "foo\nc\n"},
+			want:    []wantLine{{3, 4, "foo", true, 1}},
+		},
+		{
+			name:    "line numbers and offsets across chunks",
+			pattern: "foo",
+			before:  1,
+			after:   1,
+			chunks:  []string{"x\nfoo\n", "y\nz\nfoo\n"},
+			want: []wantLine{
+				{1, 0, "x", false, 0},
+				{2, 2, "foo", true, 1},
+				{3, 6, "y", false, 0},
+				{4, 8, "z", false, 0},
+				{5, 10, "foo", true, 1},
+			},
+		},
+		{
+			name:    "before context from previous chunk",
+			pattern: "foo",
+			before:  2,
+			chunks:  []string{"a\nb\nc\n", "foo\n"},
+			want: []wantLine{
+				{2, 2, "b", false, 0},
+				{3, 4, "c", false, 0},
+				{4, 6, "foo", true, 1},
+			},
+		},
+		{
+			name:    "after context into next chunk",
+			pattern: "foo",
+			after:   2,
+			chunks:  []string{"foo\n", "a\nb\nc\n"},
+			want: []wantLine{
+				{1, 0, "foo", true, 1},
+				{2, 4, "a", false, 0},
+				{3, 6, "b", false, 0},
+			},
+		},
+		{
+			name:    "overlapped context windows",
+			pattern: "foo",
+			before:  1,
+			after:   1,
+			chunks:  []string{"foo\nx\n", "foo\ny\nz\n"},
+			want: []wantLine{
+				{1, 0, "foo", true, 1},
+				{2, 4, "x", false, 0},
+				{3, 6, "foo", true, 1},
+				{4, 10, "y", false, 0},
+			},
+		},
+		{
+			name:    "crlf",
+			pattern: "foo",
+			chunks:  []string{"a\r\nfoo foo\r\n"},
+			want:    []wantLine{{2, 3, "foo foo", true, 2}},
+		},
+		{
+			name:    "crlf end anchor",
+			pattern: "foo$",
+			regex:   true,
+			chunks:  []string{"foo\r\nfoox\r\n", "xfoo\r\n"},
+			want: []wantLine{
+				{1, 0, "foo", true, 1},
+				{3, 11, "xfoo", true, 1},
+			},
+		},
+		{
+			name:    "empty line",
+			pattern: "^$",
+			regex:   true,
+			chunks:  []string{"a\n\nb\n"},
+			want:    []wantLine{{2, 2, "", true, 1}},
+		},
+		{
+			name:    "last line without terminator",
+			pattern: "foo",
+			chunks:  []string{"a\n", "foo"},
+			want:    []wantLine{{2, 2, "foo", true, 1}},
+		},
+		{
+			name:    "regex candidate across lines",
+			pattern: `a\s+b`,
+			regex:   true,
+			chunks:  []string{"a\nb\n", "a  b\n"},
+			want:    []wantLine{{3, 4, "a  b", true, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFilter(tt.pattern, tt.regex, tt.before, tt.after)
+			got := searchChunks(f, tt.chunks)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d lines %+v, want %d", len(got), got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if g.Line != w.line || g.Offset != w.offset || g.Content != w.content || g.Hit != w.hit || len(g.Matches) != w.matches {
+					t.Errorf("line %d: got {%d %d %q %v %d}, want %+v", i, g.Line, g.Offset, g.Content, g.Hit, len(g.Matches), w)
+				}
+			}
+
+			// the same lines when the whole input is one chunk
+			whole := searchAll(newTestFilter(tt.pattern, tt.regex, tt.before, tt.after), []byte(strings.Join(tt.chunks, "")))
+			if fmt.Sprint(whole) != fmt.Sprint(got) {
+				t.Errorf("whole input: got %+v, want %+v", whole, got)
+			}
+		})
+	}
+}
+
+// TestSearchContentMatchesLineScan inputs bigger than the read buffer, so lines cross reads and the buffer grows
+func TestSearchContentMatchesLineScan(t *testing.T) {
+	var many strings.Builder
+	for i := 0; many.Len() < 3*searchBufSize; i++ {
+		if i%97 == 0 {
+			fmt.Fprintf(&many, "line %d has foo and foo\n", i)
+		} else {
+			fmt.Fprintf(&many, "line %d is plain text\n", i)
+		}
+	}
+	long := "a\nfoo " + strings.Repeat("x", 2*searchBufSize) + " foo\nb\n" + strings.Repeat("y", searchBufSize+7) + "\nfoo"
+
+	inputs := map[string]string{
+		"many lines":         many.String(),
+		"crlf":               strings.ReplaceAll(many.String(), "\n", "\r\n"),
+		"long lines":         long,
+		"no final line feed": strings.TrimSuffix(many.String(), "\n"),
+	}
+	patterns := []struct {
+		pattern string
+		regex   bool
+	}{
+		{"foo", false},
+		{`foo$`, true},
+		{`h[^z]*foo`, true},
+		{`^line \d+3 `, true},
+		{`t *$`, true},
+	}
+	contexts := [][2]int{{0, 0}, {2, 0}, {0, 3}, {4, 4}}
+
+	for name, input := range inputs {
+		for _, p := range patterns {
+			for _, c := range contexts {
+				t.Run(fmt.Sprintf("%s/%s/B%dA%d", name, p.pattern, c[0], c[1]), func(t *testing.T) {
+					want := lineScan(newTestFilter(p.pattern, p.regex, c[0], c[1]), strings.NewReader(input))
+					got := searchAll(newTestFilter(p.pattern, p.regex, c[0], c[1]), []byte(input))
+					if len(got) != len(want) {
+						t.Fatalf("got %d lines, want %d", len(got), len(want))
+					}
+					for i := range want {
+						if fmt.Sprint(got[i]) != fmt.Sprint(want[i]) {
+							t.Fatalf("line %d: got %+v, want %+v", i, got[i], want[i])
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// benchInput about 8MiB of text, a hit on every line or on none
+func benchInput(dense bool) []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < 8<<20; i++ {
+		if dense {
+			fmt.Fprintf(&b, "%d: the quick brown fox jumps over the lazy dog\n", i)
+		} else {
+			fmt.Fprintf(&b, "%d: the quick brown cat jumps over the lazy dog\n", i)
+		}
+	}
+	return b.Bytes()
+}
+
+// BenchmarkSearch the line scanner, searchContent, and DoFilter which opens and searches a real file
+func BenchmarkSearch(b *testing.B) {
+	dir := b.TempDir() + "/"
+	for _, input := range []struct {
+		name  string
+		dense bool
+	}{{"no-match", false}, {"match-dense", true}} {
+		data := benchInput(input.dense)
+		if err := ioutil.WriteFile(dir+input.name, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+		info, err := os.Stat(dir + input.name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, p := range []struct {
+			name    string
+			pattern string
+			regex   bool
+		}{{"literal", "fox", false}, {"regex", `f[aeiou]x`, true}} {
+			f := newTestFilter(p.pattern, p.regex, 0, 0)
+
+			b.Run(input.name+"/"+p.name+"/line-scan", func(b *testing.B) {
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					lineScan(f, bytes.NewReader(data))
+				}
+			})
+			b.Run(input.name+"/"+p.name+"/search-content", func(b *testing.B) {
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					searchAll(f, data)
+				}
+			})
+			b.Run(input.name+"/"+p.name+"/do-filter", func(b *testing.B) {
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					if pass, _ := f.DoFilter(context.Background(), info, dir); pass != input.dense {
+						b.Fatalf("got result %v", pass)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestCancelledSearchIsNoResult a file which is not searched to the end has no hit, and no missing hit either
+func TestCancelledSearchIsNoResult(t *testing.T) {
+	f := newTestFilter("foo", false, 0, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	file, err := os.Stat(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := &contentNode{f: f, matcher: f.Cfg.contentMatcher}
+	for name, node := range map[string]exprNode{
+		"content":  content,
+		"not":      &notNode{sub: content},
+		"no match": &noMatchNode{sub: content},
+	} {
+		e := &evalEnv{ctx: ctx, file: file, reader: func() io.Reader { return strings.NewReader("bar\n") }}
+		if node.eval(e) {
+			t.Errorf("%s: got result for cancelled search", name)
+		}
+		if e.reason != youtput.SkipCancelled {
+			t.Errorf("%s: got reason %q, want %q", name, e.reason, youtput.SkipCancelled)
+		}
+	}
+}
+
+// TestNotRegularIsNoResult directories and symlinks to them have no content, so they have no missing hit either
+func TestNotRegularIsNoResult(t *testing.T) {
+	f := newTestFilter("foo", false, 0, 0)
+	dir := t.TempDir()
+	if err := os.Mkdir(dir+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub", dir+"/linkdir"); err != nil {
+		t.Fatal(err)
+	}
+
+	content := &contentNode{f: f, matcher: f.Cfg.contentMatcher}
+	for _, name := range []string{"sub", "linkdir"} {
+		file, err := os.Lstat(dir + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for node, n := range map[string]exprNode{"no match": &noMatchNode{sub: content}, "not": &notNode{sub: content}} {
+			e := &evalEnv{ctx: context.Background(), file: file, baseDir: dir + "/"}
+			if n.eval(e) {
+				t.Errorf("%s %s: got result", node, name)
+			}
+			if e.reason != youtput.SkipNotRegular {
+				t.Errorf("%s %s: got reason %q, want %q", node, name, e.reason, youtput.SkipNotRegular)
+			}
+		}
+	}
+}
+
+// TestDoFilterReaderStreams hit lines of a stream are emitted as they are read, before the stream ends
+func TestDoFilterReaderStreams(t *testing.T) {
+	f := newTestFilter("foo", false, 0, 0)
+	// a regular file passes the kind filter, like stdin redirected from a file
+	file, err := ioutil.TempFile(t.TempDir(), "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	info, err := os.Stat(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []youtput.FileItem
+	emit := func(o youtput.FileItem) { parts = append(parts, o) }
+	pass, last := f.DoFilterReader(context.Background(), iotest.OneByteReader(strings.NewReader("foo\nbar\nfoo\n")), info, emit)
+	if !pass {
+		t.Fatal("got no result")
+	}
+	parts = append(parts, last)
+
+	var lines []youtput.FileItemLine
+	for i, part := range parts {
+		if part.Continued != (i > 0) || part.Partial != (i < len(parts)-1) {
+			t.Errorf("part %d: got Continued %v Partial %v", i, part.Continued, part.Partial)
+		}
+		lines = append(lines, part.Lines...)
+	}
+	if len(parts) < 3 || len(lines) != 2 || lines[0].Line != 1 || lines[1].Line != 3 {
+		t.Errorf("got %d parts with lines %+v", len(parts), lines)
+	}
+	if f.Stats.MatchedFiles != 1 || f.Stats.MatchedLines != 2 {
+		t.Errorf("got %d matched files, %d matched lines", f.Stats.MatchedFiles, f.Stats.MatchedLines)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build linux || openbsd || dragonfly
+// +build linux openbsd dragonfly
+
+package yfilter
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime
+func accessTime(file os.FileInfo) time.Time {
+	if st, ok := file.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec))
+	}
+	return file.ModTime()
+}
+
+// changeTime status change time
+func changeTime(file os.FileInfo) time.Time {
+	if st, ok := file.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(int64(st.Ctim.Sec), int64(st.Ctim.Nsec))
+	}
+	return file.ModTime()
+}
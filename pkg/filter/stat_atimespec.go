@@ -0,0 +1,26 @@
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package yfilter
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime
+func accessTime(file os.FileInfo) time.Time {
+	if st, ok := file.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(int64(st.Atimespec.Sec), int64(st.Atimespec.Nsec))
+	}
+	return file.ModTime()
+}
+
+// changeTime status change time
+func changeTime(file os.FileInfo) time.Time {
+	if st, ok := file.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(int64(st.Ctimespec.Sec), int64(st.Ctimespec.Nsec))
+	}
+	return file.ModTime()
+}
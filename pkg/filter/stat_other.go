@@ -0,0 +1,19 @@
+//go:build !linux && !openbsd && !dragonfly && !darwin && !freebsd && !netbsd
+// +build !linux,!openbsd,!dragonfly,!darwin,!freebsd,!netbsd
+
+package yfilter
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime not available, modification time instead
+func accessTime(file os.FileInfo) time.Time {
+	return file.ModTime()
+}
+
+// changeTime not available, modification time instead
+func changeTime(file os.FileInfo) time.Time {
+	return file.ModTime()
+}
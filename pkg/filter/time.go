@@ -0,0 +1,100 @@
+package yfilter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// timeFormats absolute timestamp formats, local time zone if not in the format
+var timeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// durationRegexp duration with units s|m|h|d|w, like 90m, 7d, 2w, 1d12h
+var durationRegexp = regexp.MustCompile(`^(\d+[smhdw])+$`)
+var durationPartRegexp = regexp.MustCompile(`(\d+)([smhdw])`)
+
+// parseTimeRef parse a time reference
+// a duration means that long before now, otherwise an absolute timestamp,
+// otherwise a reference file whose modification time is used
+func parseTimeRef(ref string, now time.Time) (time.Time, error) {
+	if d, ok := parseAge(ref); ok {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeFormats {
+		if t, err := time.ParseInLocation(layout, ref, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if info, err := os.Stat(ref); err == nil {
+		return info.ModTime(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: not a duration, timestamp or file", ref)
+}
+
+// parseAge parse duration with day and week units
+func parseAge(s string) (time.Duration, bool) {
+	if !durationRegexp.MatchString(s) {
+		return 0, false
+	}
+
+	var d time.Duration
+	for _, part := range durationPartRegexp.FindAllStringSubmatch(s, -1) {
+		n, err := strconv.ParseInt(part[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		unit := time.Second
+		switch part[2] {
+		case "m":
+			unit = time.Minute
+		case "h":
+			unit = time.Hour
+		case "d":
+			unit = 24 * time.Hour
+		case "w":
+			unit = 7 * 24 * time.Hour
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, true
+}
+
+// filterNewer modification time after --newer
+func (f *Filter) filterNewer(file os.FileInfo, _ string) os.FileInfo {
+	if f.Cfg.newer.IsZero() || file.ModTime().After(f.Cfg.newer) {
+		return file
+	}
+	return nil
+}
+
+// filterOlder modification time before --older
+func (f *Filter) filterOlder(file os.FileInfo, _ string) os.FileInfo {
+	if f.Cfg.older.IsZero() || file.ModTime().Before(f.Cfg.older) {
+		return file
+	}
+	return nil
+}
+
+// filterChangedWithin status change time after --changed-within
+func (f *Filter) filterChangedWithin(file os.FileInfo, _ string) os.FileInfo {
+	if f.Cfg.changedWithin.IsZero() || changeTime(file).After(f.Cfg.changedWithin) {
+		return file
+	}
+	return nil
+}
+
+// filterAccessedBefore access time before --accessed-before
+func (f *Filter) filterAccessedBefore(file os.FileInfo, _ string) os.FileInfo {
+	if f.Cfg.accessedBefore.IsZero() || accessTime(file).Before(f.Cfg.accessedBefore) {
+		return file
+	}
+	return nil
+}
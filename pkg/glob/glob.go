@@ -0,0 +1,154 @@
+package yglob
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Glob compiled shell glob pattern
+//
+// supported syntax:
+//   - "*" any sequence of non-separator characters
+//   - "**" any sequence of characters including separators, when it is a whole path segment
+//   - "?" any single non-separator character
+//   - "[abc]" character class, "[!abc]" or "[^abc]" for negation
+//   - "{a,b}" alternation, can be nested
+//   - "\x" escape the next character
+type Glob struct {
+	Pattern string
+	re      *regexp.Regexp
+}
+
+// Compile compile glob pattern into Glob
+func Compile(pattern string, caseSensitive bool) (*Glob, error) {
+	expr, err := toRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %s", pattern, err)
+	}
+	if !caseSensitive {
+		expr = "(?i)" + expr
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %s", pattern, err)
+	}
+	return &Glob{Pattern: pattern, re: re}, nil
+}
+
+// Match check if the whole name matches the glob
+func (g *Glob) Match(name string) bool {
+	return g.re.MatchString(name)
+}
+
+// toRegexp translate glob pattern into anchored regular expression
+func toRegexp(pattern string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	braceDepth := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				segStart := i == 0 || pattern[i-1] == '/'
+				segEnd := i+2 == len(pattern) || pattern[i+2] == '/'
+				if segStart && segEnd {
+					if i+2 < len(pattern) { // "**/" zero or more directories
+						sb.WriteString("(?:.*/)?")
+						i += 2
+					} else { // trailing "**" everything
+						sb.WriteString(".*")
+						i++
+					}
+					continue
+				}
+				i++
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end, class, err := translateClass(pattern, i)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(class)
+			i = end
+		case '{':
+			braceDepth++
+			sb.WriteString("(?:")
+		case '}':
+			if braceDepth == 0 {
+				sb.WriteString(regexp.QuoteMeta("}"))
+				continue
+			}
+			braceDepth--
+			sb.WriteString(")")
+		case ',':
+			if braceDepth > 0 {
+				sb.WriteString("|")
+			} else {
+				sb.WriteString(",")
+			}
+		case '\\':
+			if i+1 == len(pattern) {
+				return "", fmt.Errorf("trailing escape")
+			}
+			i++
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	if braceDepth != 0 {
+		return "", fmt.Errorf("unclosed brace")
+	}
+
+	sb.WriteString("$")
+	return sb.String(), nil
+}
+
+// translateClass translate [...] starting at pattern[start]
+// return the index of the closing bracket and the regexp class
+func translateClass(pattern string, start int) (int, string, error) {
+	var sb strings.Builder
+	sb.WriteString("[")
+
+	i := start + 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		sb.WriteString("^")
+		i++
+	}
+	// a leading ] is a literal member of the class
+	if i < len(pattern) && pattern[i] == ']' {
+		sb.WriteString(`\]`)
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case ']':
+			sb.WriteString("]")
+			return i, sb.String(), nil
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				c = pattern[i]
+			}
+			if c == '-' {
+				sb.WriteString(`\-`)
+			} else {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		case '[', '^':
+			sb.WriteString(`\`)
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return 0, "", fmt.Errorf("unclosed character class")
+}
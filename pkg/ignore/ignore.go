@@ -0,0 +1,199 @@
+package yignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yglob "github.com/fhquthpdw/yfind/pkg/glob"
+	"github.com/mitchellh/go-homedir"
+)
+
+// IgnoreFiles per directory ignore files, loaded in this order so later files win
+var IgnoreFiles = []string{".gitignore", ".ignore", ".yfindignore"}
+
+// rule one line of an ignore file
+type rule struct {
+	glob     *yglob.Glob
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// Matcher ignore rules of one directory level
+// every level keeps a pointer to its parent, so a directory inherits its parents' rules
+// and rules of deeper levels take precedence, the same as git does
+type Matcher struct {
+	parent *Matcher
+	dir    string
+	// for the levels above the search root: dir is the root, prefix is the path of the root
+	// relative to the directory of the rules
+	prefix string
+	rules  []rule
+}
+
+// NewMatcher create the matcher for the search root
+// if the root is inside a git repository, the ignore files from the repository top down to the root
+// are loaded too. global ignore file is loaded first and scoped to the repository top, or to the root
+func NewMatcher(root string) *Matcher {
+	root = strings.TrimRight(root, "/")
+	ancestors, prefixes := repoAncestors(root)
+
+	m := &Matcher{dir: root}
+	if len(prefixes) > 0 {
+		m.prefix = prefixes[0]
+	}
+	if global := globalIgnoreFile(); global != "" {
+		m.rules = append(m.rules, loadRules(global)...)
+	}
+	for i, dir := range ancestors {
+		if rules := dirRules(dir); len(rules) > 0 {
+			m = &Matcher{parent: m, dir: root, prefix: prefixes[i], rules: rules}
+		}
+	}
+	return m.Child(root)
+}
+
+// Child load ignore files of dir and return the matcher for it
+// if dir has no ignore file, the parent matcher is returned
+func (m *Matcher) Child(dir string) *Matcher {
+	dir = strings.TrimRight(dir, "/")
+	rules := dirRules(dir)
+	if len(rules) == 0 {
+		return m
+	}
+	return &Matcher{parent: m, dir: dir, rules: rules}
+}
+
+// Ignored check if path is ignored
+// the last matching rule of the deepest level which has a matching rule decides
+func (m *Matcher) Ignored(path string, isDir bool) bool {
+	base := path[strings.LastIndex(path, "/")+1:]
+	for level := m; level != nil; level = level.parent {
+		rel := level.prefix + strings.TrimPrefix(path, level.dir+"/")
+		for i := len(level.rules) - 1; i >= 0; i-- {
+			r := level.rules[i]
+			if r.dirOnly && !isDir {
+				continue
+			}
+			subject := base
+			if r.anchored {
+				subject = rel
+			}
+			if r.glob.Match(subject) {
+				return !r.negate
+			}
+		}
+	}
+	return false
+}
+
+// dirRules rules of the ignore files in dir
+func dirRules(dir string) (rules []rule) {
+	rules = append(rules, loadRules(filepath.Join(dir, ".git", "info", "exclude"))...)
+	for _, name := range IgnoreFiles {
+		rules = append(rules, loadRules(filepath.Join(dir, name))...)
+	}
+	return
+}
+
+// repoAncestors directories from the top of the git repository which root is in, down to the parent of root,
+// and the path of root relative to each of them with trailing "/".
+// none if root is the repository top or not in a repository
+func repoAncestors(root string) (dirs []string, prefixes []string) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, nil
+	}
+
+	for dir := abs; ; {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// not in a repository
+			return nil, nil
+		}
+		dir = parent
+		dirs = append([]string{dir}, dirs...)
+	}
+
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			return nil, nil
+		}
+		prefixes = append(prefixes, filepath.ToSlash(rel)+"/")
+	}
+	return dirs, prefixes
+}
+
+// loadRules parse ignore file, missing or unreadable file has no rules
+func loadRules(file string) (rules []rule) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseRule(scanner.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return
+}
+
+// parseRule parse one line in gitignore format
+func parseRule(line string) (r rule, ok bool) {
+	line = strings.TrimRight(line, "\r")
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// a slash at the beginning or in the middle anchors the pattern to the ignore file's directory
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return
+	}
+
+	g, err := yglob.Compile(line, true)
+	if err != nil {
+		return
+	}
+	r.glob = g
+	return r, true
+}
+
+// globalIgnoreFile git's default global ignore file
+func globalIgnoreFile() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "git", "ignore")
+}
@@ -0,0 +1,255 @@
+package yignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree create files under a temp dir, the global ignore file is moved out of the way
+func writeTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	configHome, hadConfigHome := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, ".config"))
+	t.Cleanup(func() {
+		if hadConfigHome {
+			os.Setenv("XDG_CONFIG_HOME", configHome)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	})
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+type ignoreCase struct {
+	path  string // under the search root
+	isDir bool
+	want  bool
+}
+
+// checkIgnored match every path with the matcher of its directory, built the same way as the walker does
+func checkIgnored(t *testing.T, root string, cases []ignoreCase) {
+	t.Helper()
+	for _, c := range cases {
+		m := NewMatcher(root)
+		dir := root
+		parts := strings.Split(c.path, "/")
+		for _, part := range parts[:len(parts)-1] {
+			dir += "/" + part
+			m = m.Child(dir)
+		}
+		if got := m.Ignored(root+"/"+c.path, c.isDir); got != c.want {
+			t.Errorf("%s (dir %v): got ignored %v, want %v", c.path, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		cases []ignoreCase
+	}{
+		{
+			name: "deeper negation overrides parent rule",
+			files: map[string]string{
+				".gitignore":     "*.log\n",
+				"sub/.gitignore": "!keep.log\n",
+			},
+			cases: []ignoreCase{
+				{path: "keep.log", want: true},
+				{path: "sub/keep.log", want: false},
+				{path: "sub/other.log", want: true},
+				{path: "sub/deeper/keep.log", want: false},
+			},
+		},
+		{
+			name: "later ignore file wins",
+			files: map[string]string{
+				".gitignore": "*.log\n!keep.log\n",
+				".ignore":    "keep.log\n",
+			},
+			cases: []ignoreCase{
+				{path: "keep.log", want: true},
+				{path: "a.log", want: true},
+			},
+		},
+		{
+			name: "anchored and unanchored",
+			files: map[string]string{
+				".gitignore": "/build\nout\ndoc/*.txt\n",
+			},
+			cases: []ignoreCase{
+				{path: "build", isDir: true, want: true},
+				{path: "sub/build", isDir: true, want: false},
+				{path: "out", isDir: true, want: true},
+				{path: "sub/out", want: true},
+				{path: "doc/a.txt", want: true},
+				{path: "sub/doc/a.txt", want: false},
+				{path: "doc/sub/a.txt", want: false},
+			},
+		},
+		{
+			name: "anchored in sub directory",
+			files: map[string]string{
+				"sub/.gitignore": "/gen\n",
+			},
+			cases: []ignoreCase{
+				{path: "gen", isDir: true, want: false},
+				{path: "sub/gen", isDir: true, want: true},
+				{path: "sub/x/gen", isDir: true, want: false},
+			},
+		},
+		{
+			name: "directory only",
+			files: map[string]string{
+				".gitignore": "tmp/\n",
+			},
+			cases: []ignoreCase{
+				{path: "tmp", isDir: true, want: true},
+				{path: "tmp", isDir: false, want: false},
+				{path: "sub/tmp", isDir: true, want: true},
+			},
+		},
+		{
+			name: "trailing spaces",
+			files: map[string]string{
+				".gitignore": "foo\\ \nbar  \n",
+			},
+			cases: []ignoreCase{
+				{path: "foo ", want: true},
+				{path: "foo", want: false},
+				{path: "bar", want: true},
+				{path: "bar  ", want: false},
+			},
+		},
+		{
+			name: "comments and escapes",
+			files: map[string]string{
+				".gitignore": "# comment\n\\#hash\n\\!bang\n",
+			},
+			cases: []ignoreCase{
+				{path: "# comment", want: false},
+				{path: "#hash", want: true},
+				{path: "!bang", want: true},
+			},
+		},
+		{
+			name: "double star",
+			files: map[string]string{
+				".gitignore": "**/gen/*.go\na/**/b\nlogs/**\n",
+			},
+			cases: []ignoreCase{
+				{path: "gen/x.go", want: true},
+				{path: "src/pkg/gen/x.go", want: true},
+				{path: "src/gen/x.txt", want: false},
+				{path: "a/b", isDir: true, want: true},
+				{path: "a/x/y/b", isDir: true, want: true},
+				{path: "x/a/b", isDir: true, want: false},
+				{path: "logs/x", want: true},
+				{path: "logs/x/y", want: true},
+			},
+		},
+		{
+			name: "git info exclude",
+			files: map[string]string{
+				".git/info/exclude": "*.tmp\n",
+			},
+			cases: []ignoreCase{
+				{path: "a.tmp", want: true},
+				{path: "sub/a.tmp", want: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkIgnored(t, writeTree(t, tt.files), tt.cases)
+		})
+	}
+}
+
+// TestIgnoredAboveRoot ignore files from the repository top down to a root in a sub directory apply
+func TestIgnoredAboveRoot(t *testing.T) {
+	top := writeTree(t, map[string]string{
+		".git/info/exclude":  "*.tmp\n",
+		".gitignore":         "*.log\n/src/gen/*.go\n/src/vendor/\n",
+		"src/.gitignore":     "!keep.log\n",
+		"src/gen/.gitignore": "/local.txt\n",
+		"src/gen/x.go":       "",
+	})
+
+	// root below the repository top
+	checkIgnored(t, top+"/src", []ignoreCase{
+		{path: "z.log", want: true},
+		{path: "keep.log", want: false},
+		{path: "a.tmp", want: true},
+		{path: "gen/x.go", want: true},
+		{path: "gen/x.txt", want: false},
+		{path: "gen/local.txt", want: true},
+		{path: "x.go", want: false},
+		{path: "vendor", isDir: true, want: true},
+		{path: "vendor", isDir: false, want: false},
+	})
+
+	// root two levels below the top, the anchored rule of the top is matched with the full prefix
+	checkIgnored(t, top+"/src/gen", []ignoreCase{
+		{path: "x.go", want: true},
+		{path: "y.txt", want: false},
+		{path: "local.txt", want: true},
+		{path: "z.log", want: true},
+		{path: "keep.log", want: false},
+	})
+}
+
+// TestIgnoredOutsideRepository without .git above the root, only the root and its sub directories count
+func TestIgnoredOutsideRepository(t *testing.T) {
+	top := writeTree(t, map[string]string{
+		".gitignore":     "*.log\n",
+		"src/.gitignore": "*.tmp\n",
+	})
+	if _, err := os.Stat(filepath.Join(filepath.Dir(top), ".git")); err == nil {
+		t.Skip("temp dir is inside a git repository")
+	}
+
+	checkIgnored(t, top+"/src", []ignoreCase{
+		{path: "z.log", want: false},
+		{path: "a.tmp", want: true},
+	})
+}
+
+func TestRepoAncestors(t *testing.T) {
+	top := writeTree(t, map[string]string{
+		".git/HEAD":  "",
+		"a/b/c/file": "",
+	})
+
+	dirs, prefixes := repoAncestors(top + "/a/b")
+	if len(dirs) != 2 || dirs[0] != top || dirs[1] != top+"/a" {
+		t.Errorf("got dirs %v", dirs)
+	}
+	if len(prefixes) != 2 || prefixes[0] != "a/b/" || prefixes[1] != "b/" {
+		t.Errorf("got prefixes %v", prefixes)
+	}
+
+	if dirs, _ := repoAncestors(top); len(dirs) != 0 {
+		t.Errorf("repository top: got dirs %v", dirs)
+	}
+}
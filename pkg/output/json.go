@@ -0,0 +1,185 @@
+package youtput
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// JSON Lines output, one event per line, schema compatible with ripgrep --json
+// every result file is a begin event, one match or context event per line, and an end event,
+// the run ends with a summary event
+
+// jsonEvent
+type jsonEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// jsonText text, or base64 encoded bytes when it is not valid UTF-8
+type jsonText struct {
+	Text  *string `json:"text,omitempty"`
+	Bytes *string `json:"bytes,omitempty"`
+}
+
+// jsonBegin kind is not in ripgrep schema, regular file has none
+type jsonBegin struct {
+	Path jsonText `json:"path"`
+	Kind string   `json:"kind,omitempty"`
+}
+
+type jsonSubmatch struct {
+	Match jsonText `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+type jsonMatch struct {
+	Path           jsonText       `json:"path"`
+	Lines          jsonText       `json:"lines"`
+	LineNumber     int64          `json:"line_number"`
+	AbsoluteOffset int64          `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+type jsonDuration struct {
+	Secs  int64  `json:"secs"`
+	Nanos int64  `json:"nanos"`
+	Human string `json:"human"`
+}
+
+type jsonStats struct {
+	Elapsed           jsonDuration `json:"elapsed"`
+	Searches          int64        `json:"searches"`
+	SearchesWithMatch int64        `json:"searches_with_match"`
+	BytesSearched     int64        `json:"bytes_searched"`
+	BytesPrinted      int64        `json:"bytes_printed"`
+	MatchedLines      int64        `json:"matched_lines"`
+	Matches           int64        `json:"matches"`
+}
+
+type jsonEnd struct {
+	Path         jsonText  `json:"path"`
+	BinaryOffset *int64    `json:"binary_offset"`
+	Stats        jsonStats `json:"stats"`
+}
+
+type jsonSummary struct {
+	ElapsedTotal jsonDuration `json:"elapsed_total"`
+	Stats        jsonStats    `json:"stats"`
+}
+
+// jsonOutput output one result file as begin, match and end events
+func (o *Output) jsonOutput(fileItem FileItem) {
+	path := newJSONText(fileItem.FileName)
+	kind := fileItem.Kind
+	if kind == KindFile {
+		kind = ""
+	}
+	if !fileItem.Continued {
+		o.writeJSON("begin", jsonBegin{Path: path, Kind: kind})
+	}
+
+	var matchedLines, matches int64
+	for _, l := range fileItem.Lines {
+		submatches := make([]jsonSubmatch, 0, len(l.Matches))
+		for _, m := range l.Matches {
+			if m.End > len(l.Content) {
+				continue
+			}
+			submatches = append(submatches, jsonSubmatch{
+				Match: newJSONText(l.Content[m.Start:m.End]),
+				Start: m.Start,
+				End:   m.End,
+			})
+		}
+		eventType := "context"
+		if l.Hit {
+			eventType = "match"
+			matchedLines++
+			matches += int64(len(submatches))
+		}
+
+		o.writeJSON(eventType, jsonMatch{
+			Path:           path,
+			Lines:          newJSONText(l.Content + "\n"),
+			LineNumber:     l.Line,
+			AbsoluteOffset: l.Offset,
+			Submatches:     submatches,
+		})
+	}
+
+	// the end event of a file output in parts counts the lines of all parts
+	if fileItem.Partial {
+		o.partLines += matchedLines
+		o.partMatches += matches
+		return
+	}
+	matchedLines += o.partLines
+	matches += o.partMatches
+	o.partLines, o.partMatches = 0, 0
+
+	var binaryOffset *int64
+	if fileItem.Binary {
+		binaryOffset = &fileItem.BinaryOffset
+	}
+	o.writeJSON("end", jsonEnd{
+		Path:         path,
+		BinaryOffset: binaryOffset,
+		Stats: jsonStats{
+			Elapsed:           newJSONDuration(fileItem.Elapsed),
+			Searches:          1,
+			SearchesWithMatch: 1,
+			BytesSearched:     fileItem.BytesRead,
+			MatchedLines:      matchedLines,
+			Matches:           matches,
+		},
+	})
+}
+
+// jsonSummary output the summary event of the run
+func (o *Output) jsonSummary(s *Stats, elapsed time.Duration) {
+	o.writeJSON("summary", jsonSummary{
+		ElapsedTotal: newJSONDuration(elapsed),
+		Stats: jsonStats{
+			Elapsed:           newJSONDuration(elapsed),
+			Searches:          atomic.LoadInt64(&s.Files),
+			SearchesWithMatch: atomic.LoadInt64(&s.MatchedFiles),
+			BytesSearched:     atomic.LoadInt64(&s.BytesRead),
+			MatchedLines:      atomic.LoadInt64(&s.MatchedLines),
+			Matches:           atomic.LoadInt64(&s.Matches),
+		},
+	})
+}
+
+// writeJSON write one event line
+// html characters are not escaped, so labels like <stdin> are kept as they are
+func (o *Output) writeJSON(eventType string, data interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(jsonEvent{Type: eventType, Data: data}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// newJSONText
+func newJSONText(s string) jsonText {
+	if utf8.ValidString(s) {
+		return jsonText{Text: &s}
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	return jsonText{Bytes: &encoded}
+}
+
+// newJSONDuration
+func newJSONDuration(d time.Duration) jsonDuration {
+	return jsonDuration{
+		Secs:  int64(d / time.Second),
+		Nanos: int64(d % time.Second),
+		Human: fmt.Sprintf("%fs", d.Seconds()),
+	}
+}
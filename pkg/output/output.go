@@ -2,8 +2,10 @@ package youtput
 
 import (
 	"fmt"
-	"strings"
+	"os"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
 )
@@ -12,54 +14,187 @@ func NewOutput(filterFileName, filterFileContent string) *Output {
 	return &Output{
 		FilterFileName:    filterFileName,
 		FilterFileContent: filterFileContent,
+		ShowLines:         filterFileContent != "",
+		Mode:              ModeColor,
 	}
 }
 
+// Match byte span [Start, End) of one match in a line
+type Match struct {
+	Start int
+	End   int
+}
+
 type FileItemLine struct {
-	Line    int64
-	Content string
-	Hit     bool
+	Line         int64
+	Offset       int64 // byte offset of the line start in the file
+	Content      string
+	Hit          bool
+	Matches      []Match
+	TrimmedLeft  int // bytes trimmed from the line start by --max-columns
+	TrimmedRight int // bytes trimmed from the line end by --max-columns
 }
 
+// entry kinds
+const (
+	KindFile          = "f"
+	KindDir           = "d"
+	KindSymlink       = "l"
+	KindBrokenSymlink = "x"
+	KindPipe          = "p"
+	KindSocket        = "s"
+	KindBlockDevice   = "b"
+	KindCharDevice    = "c"
+	KindOther         = "?"
+)
+
 type FileItem struct {
-	FileName string
-	FileSize int64
-	Lines    []FileItemLine
+	FileName     string
+	FileSize     int64
+	Kind         string
+	LinkTarget   string // target of symlink
+	NameMatches  []Match
+	Lines        []FileItemLine
+	Binary       bool
+	BinaryOffset int64 // offset of the first NUL byte in binary file
+	BytesRead    int64
+	Elapsed      time.Duration // time cost of content scan
+	// a stream is output in parts as its lines are found:
+	// every part but the last is Partial, every part but the first is Continued
+	Partial   bool
+	Continued bool
 }
 
+// output modes
+const (
+	ModeColor = "color"
+	ModeJSON  = "json"
+	ModePlain = "plain" // bare paths only, for xargs and while read
+)
+
 type Output struct {
 	FilterFileName    string
 	FilterFileContent string
+	Sort              bool
+	Mode              string
+	ShowContext       bool
+	ShowLines         bool // content is searched, by --content or in --where
+	Print0            bool // plain paths are terminated by NUL instead of newline
+	// state of the file being output in parts, see FileItem.Partial
+	lastLine               int64
+	partLines, partMatches int64
+}
+
+// SetPrint0 terminate plain paths by NUL, file names may have spaces and newlines
+func (o *Output) SetPrint0(print0 bool) *Output {
+	o.Print0 = print0
+	return o
+}
+
+// SetShowLines output matched lines of content search
+func (o *Output) SetShowLines(showLines bool) *Output {
+	o.ShowLines = showLines
+	return o
+}
+
+// SetShowContext lines have context lines, separate non-contiguous groups
+func (o *Output) SetShowContext(showContext bool) *Output {
+	o.ShowContext = showContext
+	return o
+}
+
+// SetMode set output mode: color|json
+func (o *Output) SetMode(mode string) *Output {
+	o.Mode = mode
+	return o
+}
+
+// SetSort buffer all results and output them sorted by file name
+// results come from parallel walkers, so the order is random without it
+func (o *Output) SetSort(sort bool) *Output {
+	o.Sort = sort
+	return o
 }
 
 func (o *Output) Output(wg *sync.WaitGroup, fileItemChan chan FileItem) {
 	defer wg.Done()
 
+	var sorted []FileItem
 	looping := true
 	for looping {
 		select {
 		case fileItem := <-fileItemChan:
 			if fileItem.FileName == "" && len(fileItem.Lines) == 0 {
 				looping = false
+			} else if o.Sort {
+				sorted = append(sorted, fileItem)
 			} else {
-				o.colorOutput(fileItem)
+				o.output(fileItem)
 			}
 		}
 	}
 
+	// stable, so the parts of a file keep their order
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].FileName < sorted[j].FileName })
+	for _, fileItem := range sorted {
+		o.output(fileItem)
+	}
+
 	return
 }
 
+// PrintSummary print summary at the end of run
+// json mode always ends with a summary event, other modes only print it when showStats is set
+// skipped special files are always reported on stderr, unless they are already in the stats
+func (o *Output) PrintSummary(s *Stats, elapsed time.Duration, showStats bool) {
+	if special := s.Skipped(SkipSpecial); special > 0 && (!showStats || o.Mode == ModeJSON) {
+		fmt.Fprintf(os.Stderr, "%d special files (fifo, socket, device) skipped in content search\n", special)
+	}
+	if o.Mode == ModeJSON {
+		o.jsonSummary(s, elapsed)
+		return
+	}
+	if showStats {
+		o.PrintStats(s, elapsed)
+	}
+}
+
+// output output one result file by mode
+func (o *Output) output(fileItem FileItem) {
+	switch o.Mode {
+	case ModeJSON:
+		o.jsonOutput(fileItem)
+	case ModePlain:
+		o.plainOutput(fileItem)
+	default:
+		o.colorOutput(fileItem)
+	}
+}
+
+// plainOutput bare path without size and colour
+func (o *Output) plainOutput(fileItem FileItem) {
+	if fileItem.Continued {
+		return
+	}
+	if o.Print0 {
+		fmt.Print(fileItem.FileName, "\x00")
+		return
+	}
+	fmt.Println(fileItem.FileName)
+}
+
 func (o *Output) colorOutput(fileItem FileItem) {
 	clFileName := color.New(color.FgCyan)
 	oclFileName := color.New(color.FgGreen)
-	o.printFileName(fileItem, clFileName, oclFileName)
+	if !fileItem.Continued {
+		o.printFileName(fileItem, clFileName, oclFileName)
+	}
 
 	clLine := color.New(color.FgRed, color.Bold, color.Italic)
 	oclLine := color.New()
 	o.printLines(fileItem, clLine, oclLine)
 
-	if o.FilterFileContent != "" {
+	if o.ShowLines && !fileItem.Partial {
 		fmt.Println("=======================================")
 		fmt.Println()
 	}
@@ -67,38 +202,129 @@ func (o *Output) colorOutput(fileItem FileItem) {
 
 func (o *Output) printFileName(fileItem FileItem, cl *color.Color, ocl *color.Color) {
 	cl.Print(">>> ")
-	cl.Print(o.formatOutputSize(fileItem.FileSize), " ")
-	if o.FilterFileName != "" {
-		o.colorTextInLine(fileItem.FileName, o.FilterFileName, cl, ocl)
+	// the size of a stream output in parts is not known yet
+	if !fileItem.Partial {
+		cl.Print(o.formatOutputSize(fileItem.FileSize), " ")
+	}
+	if len(fileItem.NameMatches) > 0 {
+		o.colorMatchesInText(fileItem.FileName, fileItem.NameMatches, cl, ocl)
 	} else {
-		_, _ = ocl.Println(fileItem.FileName)
+		_, _ = ocl.Print(fileItem.FileName)
+	}
+	o.printKind(fileItem)
+	fmt.Println()
+}
+
+// printKind print kind indicator after the file name like ls -F, regular file has none
+func (o *Output) printKind(fileItem FileItem) {
+	kindColor := color.New(color.FgMagenta)
+	switch fileItem.Kind {
+	case KindDir:
+		_, _ = kindColor.Print("/")
+	case KindSymlink:
+		_, _ = kindColor.Print("@ -> ", fileItem.LinkTarget)
+	case KindBrokenSymlink:
+		_, _ = color.New(color.FgRed).Print("@ -> ", fileItem.LinkTarget, " (broken)")
+	case KindPipe:
+		_, _ = kindColor.Print("| (fifo)")
+	case KindSocket:
+		_, _ = kindColor.Print("= (socket)")
+	case KindBlockDevice:
+		_, _ = kindColor.Print(" (block device)")
+	case KindCharDevice:
+		_, _ = kindColor.Print(" (char device)")
+	case KindOther:
+		_, _ = kindColor.Print(" (unknown kind)")
 	}
 }
 
 func (o *Output) printLines(fileItem FileItem, cl *color.Color, ocl *color.Color) {
-	if len(fileItem.Lines) == 0 {
+	if !o.ShowLines {
+		return
+	}
+	if fileItem.Binary {
+		_, _ = cl.Println("binary file matches")
 		return
 	}
-	if o.FilterFileContent == "" {
+	if len(fileItem.Lines) == 0 {
 		return
 	}
 	lineNumColor := color.New(color.FgBlue)
+	contextLineNumColor := color.New(color.FgHiBlack)
+	trimmedColor := color.New(color.FgHiBlack)
+	// line numbers start at 1, so 0 is no previous line
+	var prevLine int64
+	if fileItem.Continued {
+		prevLine = o.lastLine
+	}
 	for _, l := range fileItem.Lines {
-		_, _ = lineNumColor.Print(l.Line)
-		o.colorTextInLine(l.Content, o.FilterFileContent, cl, ocl)
+		if o.ShowContext && prevLine > 0 && l.Line != prevLine+1 {
+			fmt.Println("--")
+		}
+		prevLine = l.Line
+		if !l.Hit {
+			_, _ = contextLineNumColor.Print(l.Line)
+		} else {
+			_, _ = lineNumColor.Print(l.Line)
+		}
+		if l.TrimmedLeft > 0 {
+			_, _ = trimmedColor.Printf("[%d bytes omitted]", l.TrimmedLeft)
+		}
+		if l.TrimmedRight > 0 {
+			o.colorMatchesInText(l.Content, l.Matches, cl, ocl)
+			_, _ = trimmedColor.Printf("[%d bytes omitted]\n", l.TrimmedRight)
+		} else {
+			o.colorMatchesInLine(l.Content, l.Matches, cl, ocl)
+		}
 	}
+	o.lastLine = prevLine
 	return
 }
 
-func (o *Output) colorTextInLine(lineText, colorText string, cl *color.Color, ocl *color.Color) {
-	strArr := strings.Split(lineText, colorText)
-	for idx, t := range strArr {
-		_, _ = ocl.Print(t)
-		if idx < len(strArr)-1 {
-			_, _ = cl.Print(colorText)
+// colorMatchesInLine color every matched span in line and end the line
+func (o *Output) colorMatchesInLine(lineText string, matches []Match, cl *color.Color, ocl *color.Color) {
+	o.colorMatchesInText(lineText, matches, cl, ocl)
+	fmt.Println()
+}
+
+// colorMatchesInText color every matched span in text
+// the original text is printed so case insensitive matches keep their case
+func (o *Output) colorMatchesInText(lineText string, matches []Match, cl *color.Color, ocl *color.Color) {
+	last := 0
+	for _, m := range mergeMatches(matches) {
+		if m.End > len(lineText) {
+			continue
 		}
+		_, _ = ocl.Print(lineText[last:m.Start])
+		_, _ = cl.Print(lineText[m.Start:m.End])
+		last = m.End
 	}
-	fmt.Println()
+	_, _ = ocl.Print(lineText[last:])
+}
+
+// mergeMatches sort spans and merge the overlapped ones
+// spans may come from different matchers, e.g. --name and --glob
+func mergeMatches(matches []Match) []Match {
+	if len(matches) < 2 {
+		return matches
+	}
+
+	sorted := make([]Match, len(matches))
+	copy(sorted, matches)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	merged := sorted[:1]
+	for _, m := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if m.Start <= last.End {
+			if m.End > last.End {
+				last.End = m.End
+			}
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
 }
 
 func (o *Output) formatOutputSize(sizeByte int64) string {
@@ -111,14 +337,10 @@ func (o *Output) formatOutputSize(sizeByte int64) string {
 	sizeByteFloat := float64(sizeByte)
 	if sizeByte < KB {
 		return fmt.Sprintf("%dB", sizeByte)
-	} else {
-		if sizeByte >= KB && sizeByte < MB {
-			return fmt.Sprintf("%.2fK", sizeByteFloat/1024)
-		} else if sizeByte >= MB && sizeByte < GB {
-			return fmt.Sprintf("%.2fM", sizeByteFloat/1024/1024)
-		} else {
-			return fmt.Sprintf("%.2fG", sizeByteFloat/1024/1024/1024)
-		}
+	} else if sizeByte < MB {
+		return fmt.Sprintf("%.2fK", sizeByteFloat/1024)
+	} else if sizeByte < GB {
+		return fmt.Sprintf("%.2fM", sizeByteFloat/1024/1024)
 	}
-	return ""
+	return fmt.Sprintf("%.2fG", sizeByteFloat/1024/1024/1024)
 }
@@ -0,0 +1,109 @@
+package youtput
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// skip reasons, why a file is not a result
+const (
+	SkipKind       = "kind"
+	SkipSize       = "size"
+	SkipType       = "type"
+	SkipName       = "name"
+	SkipPath       = "path"
+	SkipTime       = "time"
+	SkipOwner      = "owner"
+	SkipPerm       = "perm"
+	SkipUnreadable = "unreadable"
+	SkipBinary     = "binary"
+	SkipSpecial    = "special"     // fifo, socket or device in content search
+	SkipNotRegular = "not regular" // directory, also by symlink, has no content to search
+	SkipWhere      = "where"       // rejected by a negated --where predicate
+	SkipCancelled  = "cancelled"   // content search stopped before the end of the file
+)
+
+// SkipReasons all skip reasons in output order
+var SkipReasons = []string{SkipKind, SkipSize, SkipType, SkipName, SkipPath, SkipTime, SkipOwner, SkipPerm, SkipUnreadable, SkipBinary, SkipSpecial, SkipNotRegular, SkipWhere, SkipCancelled}
+
+// NewStats
+func NewStats() *Stats {
+	s := &Stats{skipped: make(map[string]*int64, len(SkipReasons))}
+	for _, reason := range SkipReasons {
+		s.skipped[reason] = new(int64)
+	}
+	return s
+}
+
+// Stats counters of one run
+// counters are updated by the walkers and content workers concurrently, always through atomic
+type Stats struct {
+	Dirs         int64
+	Files        int64
+	MatchedFiles int64
+	MatchedLines int64
+	Matches      int64
+	BytesRead    int64
+	skipped      map[string]*int64 // fixed keys after NewStats, so no lock is needed
+}
+
+// AddDir
+func (s *Stats) AddDir() {
+	atomic.AddInt64(&s.Dirs, 1)
+}
+
+// AddFile
+func (s *Stats) AddFile() {
+	atomic.AddInt64(&s.Files, 1)
+}
+
+// AddMatch one matched file with its matched lines and matches in them
+func (s *Stats) AddMatch(lines, matches int) {
+	atomic.AddInt64(&s.MatchedFiles, 1)
+	s.AddLines(lines, matches)
+}
+
+// AddLines matched lines and matches of a file which is output in parts, the file is counted by AddMatch
+func (s *Stats) AddLines(lines, matches int) {
+	atomic.AddInt64(&s.MatchedLines, int64(lines))
+	atomic.AddInt64(&s.Matches, int64(matches))
+}
+
+// AddBytesRead
+func (s *Stats) AddBytesRead(n int64) {
+	atomic.AddInt64(&s.BytesRead, n)
+}
+
+// AddSkip one file skipped by reason
+func (s *Stats) AddSkip(reason string) {
+	if counter, ok := s.skipped[reason]; ok {
+		atomic.AddInt64(counter, 1)
+	}
+}
+
+// Skipped number of files skipped by reason
+func (s *Stats) Skipped(reason string) int64 {
+	if counter, ok := s.skipped[reason]; ok {
+		return atomic.LoadInt64(counter)
+	}
+	return 0
+}
+
+// PrintStats print summary of the run
+func (o *Output) PrintStats(s *Stats, elapsed time.Duration) {
+	bytesRead := atomic.LoadInt64(&s.BytesRead)
+
+	fmt.Println()
+	fmt.Println("Dirs Visited:  ", atomic.LoadInt64(&s.Dirs))
+	fmt.Println("Files Examined:", atomic.LoadInt64(&s.Files))
+	for _, reason := range SkipReasons {
+		fmt.Printf("Files Skipped (%s): %d\n", reason, s.Skipped(reason))
+	}
+	fmt.Println("Matched Files: ", atomic.LoadInt64(&s.MatchedFiles))
+	fmt.Println("Matched Lines: ", atomic.LoadInt64(&s.MatchedLines))
+	fmt.Println("Bytes Read:    ", o.formatOutputSize(bytesRead))
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		fmt.Printf("Throughput:     %s/s\n", o.formatOutputSize(int64(float64(bytesRead)/seconds)))
+	}
+}
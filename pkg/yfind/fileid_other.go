@@ -0,0 +1,14 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package yfind
+
+import "os"
+
+// fileID no device and inode on this platform, loops are not detected
+type fileID struct{}
+
+// fileIDOf
+func fileIDOf(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,24 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package yfind
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID device and inode of a file
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// fileIDOf
+func fileIDOf(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
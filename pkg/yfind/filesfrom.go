@@ -0,0 +1,63 @@
+package yfind
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	youtput "github.com/fhquthpdw/yfind/pkg/output"
+)
+
+// readFilesFrom read the file list and filter every file in it, no directory is walked
+// missing files are reported as warnings, e.g. deleted files in git diff --name-only
+func (f *Yfind) readFilesFrom(ctx context.Context, fileChan chan fileJob, outputChan chan youtput.FileItem) {
+	var r io.Reader = os.Stdin
+	if f.FilesFrom != "-" {
+		list, err := os.Open(f.FilesFrom)
+		if err != nil {
+			warn("%s", err)
+			return
+		}
+		defer list.Close()
+		r = list
+	}
+
+	delim := byte('\n')
+	if f.NullData {
+		delim = 0
+	}
+	stat := os.Stat
+	if f.Follow != FollowAll {
+		stat = os.Lstat
+	}
+
+	reader := bufio.NewReader(r)
+	for ctx.Err() == nil {
+		line, err := reader.ReadString(delim)
+		path := strings.TrimSuffix(line, string(delim))
+		if !f.NullData {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path != "" {
+			if info, statErr := stat(path); statErr != nil {
+				warn("%s", statErr)
+			} else if !f.handOver(ctx, info, entryBaseDir(path, info), fileChan, outputChan) {
+				return
+			}
+		}
+
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			warn("%s: %s", f.FilesFrom, err)
+			return
+		}
+	}
+}
+
+// entryBaseDir directory part of the path of a file entry, with trailing "/" if not empty
+func entryBaseDir(path string, info os.FileInfo) string {
+	return strings.TrimSuffix(strings.TrimRight(path, "/"), info.Name())
+}
@@ -0,0 +1,66 @@
+package yfind
+
+import (
+	"fmt"
+	"os"
+)
+
+// symlink follow modes
+const (
+	FollowNever = iota // symlinks in the walk and in --files-from are reported as links
+	FollowAll          // every symlink is resolved, like find -L
+)
+
+// dirAncestor one directory on the path from the root, for loop detection
+type dirAncestor struct {
+	id     fileID
+	path   string
+	parent *dirAncestor
+}
+
+// find ancestor with the same device and inode
+func (a *dirAncestor) find(id fileID) *dirAncestor {
+	for ; a != nil; a = a.parent {
+		if a.id == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// child ancestors of the sub directory, nil if device and inode are not available
+func (a *dirAncestor) child(info os.FileInfo, path string) *dirAncestor {
+	id, ok := fileIDOf(info)
+	if !ok {
+		return nil
+	}
+	return &dirAncestor{id: id, path: path, parent: a}
+}
+
+// resolveLink resolve symlink entry in FollowAll mode
+// dangling links are kept as links, a loop is reported by the ancestor it points to
+func (f *Yfind) resolveLink(file os.FileInfo, fName string, ancestors *dirAncestor) (os.FileInfo, *dirAncestor) {
+	if f.Follow != FollowAll || file.Mode()&os.ModeSymlink == 0 {
+		return file, nil
+	}
+
+	target, err := os.Stat(fName)
+	if err != nil {
+		warn("%s: dangling symlink: %s", fName, err)
+		return file, nil
+	}
+	if !target.IsDir() {
+		return target, nil
+	}
+	if id, ok := fileIDOf(target); ok {
+		if loop := ancestors.find(id); loop != nil {
+			return target, loop
+		}
+	}
+	return target, nil
+}
+
+// warn print a warning to stderr, it is not a result
+func warn(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "yfind: "+format+"\n", args...)
+}
@@ -0,0 +1,93 @@
+package yfind
+
+import (
+	"sync"
+
+	yignore "github.com/fhquthpdw/yfind/pkg/ignore"
+)
+
+// dirJob one directory waiting to be read
+type dirJob struct {
+	path   string
+	depth  int // root is 0
+	ignore *yignore.Matcher
+	abs    string // absolute path, only kept for more than one root
+	// directories from the root to this one, only kept when symlinks are followed
+	ancestors *dirAncestor
+}
+
+// dirQueue work-stealing queue of directories
+// every walker pushes and pops at the tail of its own deque (depth first, cache friendly),
+// an idle walker steals from the head of the others (the oldest, usually biggest subtrees)
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	deques  [][]dirJob
+	pending int // directories queued or being read
+	stopped bool
+}
+
+// newDirQueue
+func newDirQueue(walkers int) *dirQueue {
+	q := &dirQueue{deques: make([][]dirJob, walkers)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push add directory to the deque of walker id
+func (q *dirQueue) push(id int, job dirJob) {
+	q.mu.Lock()
+	q.deques[id] = append(q.deques[id], job)
+	q.pending++
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// pop get the next directory for walker id
+// block until a directory is available, return false when the whole tree is walked
+func (q *dirQueue) pop(id int) (dirJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if q.stopped {
+			return dirJob{}, false
+		}
+		if own := q.deques[id]; len(own) > 0 {
+			job := own[len(own)-1]
+			q.deques[id] = own[:len(own)-1]
+			return job, true
+		}
+		for i := 1; i < len(q.deques); i++ {
+			victim := (id + i) % len(q.deques)
+			if other := q.deques[victim]; len(other) > 0 {
+				job := other[0]
+				q.deques[victim] = other[1:]
+				return job, true
+			}
+		}
+		if q.pending == 0 {
+			return dirJob{}, false
+		}
+		q.cond.Wait()
+	}
+}
+
+// done mark one popped directory as finished
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	q.pending--
+	finished := q.pending == 0
+	q.mu.Unlock()
+	if finished {
+		q.cond.Broadcast()
+	}
+}
+
+// stop wake up all walkers and make them return, queued directories are dropped
+func (q *dirQueue) stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
@@ -0,0 +1,44 @@
+package yfind
+
+import (
+	"context"
+	"os"
+
+	youtput "github.com/fhquthpdw/yfind/pkg/output"
+)
+
+// StdinName label of stdin results
+const StdinName = "<stdin>"
+
+// StdinPiped check if stdin is a pipe or a redirected file, not a terminal
+func StdinPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular()
+}
+
+// stdinInfo file info of stdin, named StdinName
+type stdinInfo struct {
+	os.FileInfo
+}
+
+// Name
+func (i stdinInfo) Name() string {
+	return StdinName
+}
+
+// searchStdin search the content of stdin instead of walking the roots
+// hit lines are output as they are found, stdin may be a long running pipe
+func (f *Yfind) searchStdin(ctx context.Context, outputChan chan youtput.FileItem) {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		warn("%s: %s", StdinName, err)
+		return
+	}
+	emit := func(o youtput.FileItem) { outputChan <- o }
+	if pass, o := f.Filter.DoFilterReader(ctx, os.Stdin, stdinInfo{info}, emit); pass {
+		outputChan <- o
+	}
+}
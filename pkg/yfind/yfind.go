@@ -1,112 +1,401 @@
 package yfind
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	yfilter "github.com/fhquthpdw/yfind/pkg/filter"
+	yignore "github.com/fhquthpdw/yfind/pkg/ignore"
 
 	youtput "github.com/fhquthpdw/yfind/pkg/output"
 )
 
 func NewYFind(filter *yfilter.Filter, output *youtput.Output) *Yfind {
 	return &Yfind{
-		Filter: filter,
-		Output: output,
+		Threads:  runtime.NumCPU(),
+		MaxDepth: -1,
+		Filter:   filter,
+		Output:   output,
 	}
 }
 
 type Yfind struct {
-	RootPath string
-	Filter   *yfilter.Filter
-	Output   *youtput.Output
+	RootPaths []string
+	Threads   int
+	NoIgnore  bool
+	Hidden    bool
+	ShowStats bool
+	Follow    int
+	FilesFrom string // file list to search instead of walking the roots, "-" is stdin
+	NullData  bool   // file list is NUL delimited
+	Stdin     bool   // search the content of stdin instead of walking the roots
+	MaxDepth  int    // -1 is no limit
+	MinDepth  int
+	Filter    *yfilter.Filter
+	Output    *youtput.Output
+	rootSet   map[string]struct{} // absolute paths of roots, only set for more than one root
 }
 
 type FileItem youtput.FileItem
 
-func (f *Yfind) SetRootPath(path string) *Yfind {
-	if path == "" {
+// SetRootPaths set the search roots, directories or files, current directory if empty
+// duplicated roots are dropped, a root inside another root is walked on its own and skipped by the outer one
+func (f *Yfind) SetRootPaths(paths []string) *Yfind {
+	if len(paths) == 0 {
 		curPath, err := os.Getwd()
 		if err != nil {
 			log.Fatalf(err.Error())
 		}
-		path = curPath
+		paths = []string{curPath}
 	}
-	f.RootPath = path
+
+	seen := make(map[string]struct{}, len(paths))
+	f.RootPaths = nil
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+		if _, ok := seen[abs]; ok {
+			continue
+		}
+		seen[abs] = struct{}{}
+		f.RootPaths = append(f.RootPaths, path)
+	}
+	f.rootSet = nil
+	if len(f.RootPaths) > 1 {
+		f.rootSet = seen
+	}
+	f.Filter.SetRootPaths(f.RootPaths)
+	return f
+}
+
+// SetThreads set the number of directory walkers and content scan workers
+// less than 1 means runtime.NumCPU()
+func (f *Yfind) SetThreads(threads int) *Yfind {
+	if threads < 1 {
+		threads = runtime.NumCPU()
+	}
+	f.Threads = threads
+	return f
+}
+
+// SetDepth limit the depth of results, entries in the root are depth 1
+// directories at max depth are not read at all, negative max depth is no limit
+func (f *Yfind) SetDepth(minDepth, maxDepth int) *Yfind {
+	f.MinDepth = minDepth
+	f.MaxDepth = maxDepth
+	return f
+}
+
+// SetNoIgnore do not respect .gitignore, .ignore and .yfindignore files
+func (f *Yfind) SetNoIgnore(noIgnore bool) *Yfind {
+	f.NoIgnore = noIgnore
+	return f
+}
+
+// SetHidden search hidden files and directories
+func (f *Yfind) SetHidden(hidden bool) *Yfind {
+	f.Hidden = hidden
+	return f
+}
+
+// SetFollow set symlink follow mode: FollowNever|FollowAll
+// roots are always resolved, the mode applies to the walk and to --files-from
+func (f *Yfind) SetFollow(follow int) *Yfind {
+	f.Follow = follow
+	return f
+}
+
+// SetFilesFrom search the files in the list instead of walking the roots
+// the list is newline delimited, or NUL delimited if null is set, "-" reads it from stdin
+func (f *Yfind) SetFilesFrom(filesFrom string, null bool) *Yfind {
+	f.FilesFrom = filesFrom
+	f.NullData = null
+	return f
+}
+
+// SetStdin search the content of stdin instead of walking the roots
+func (f *Yfind) SetStdin(stdin bool) *Yfind {
+	f.Stdin = stdin
+	return f
+}
+
+// SetShowStats print statistics summary at the end of run
+func (f *Yfind) SetShowStats(showStats bool) *Yfind {
+	f.ShowStats = showStats
 	return f
 }
 
 func (f *Yfind) timeCostTrace(t time.Time) {
+	if f.Output.Mode != youtput.ModeColor {
+		return
+	}
 	fmt.Println("Time Cost: ", time.Since(t))
 }
 
-func (f *Yfind) Run() {
-	defer f.timeCostTrace(time.Now())
+// Run walk the tree until done or ctx is cancelled
+// on cancel, no more directory or file is started, in-flight results are still output,
+// and ctx.Err() is returned
+func (f *Yfind) Run(ctx context.Context) error {
+	start := time.Now()
+	defer f.timeCostTrace(start)
 
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	outputChan := make(chan youtput.FileItem, 10)
-	// scan files, do filter, write filtered data to channel
-	go func(wg *sync.WaitGroup, outputChan chan youtput.FileItem) {
-		f.workDir(f.RootPath, wg, outputChan)
+	// content scan jobs, bounded so the walkers block when workers are busy
+	fileChan := make(chan fileJob, f.Threads)
+
+	// walk dirs, do filter, write filtered data to channel
+	go func(wg *sync.WaitGroup, fileChan chan fileJob, outputChan chan youtput.FileItem) {
+		defer wg.Done()
+
+		var workerWg sync.WaitGroup
+		for i := 0; i < f.Threads; i++ {
+			workerWg.Add(1)
+			go f.fileWorker(ctx, &workerWg, fileChan, outputChan)
+		}
+
+		if f.Stdin {
+			f.searchStdin(ctx, outputChan)
+		} else if f.FilesFrom != "" {
+			f.readFilesFrom(ctx, fileChan, outputChan)
+		} else {
+			f.walkRoots(ctx, fileChan, outputChan)
+		}
+		close(fileChan)
+		workerWg.Wait()
 		close(outputChan)
-	}(&wg, outputChan)
+	}(&wg, fileChan, outputChan)
 
 	// get channel data and output to console
 	go f.Output.Output(&wg, outputChan)
 
-	// TODO: output scanned total dirs, files, result files, lines
-	// TODO: show time cost here
-	// TODO: catch os.Single
 	wg.Wait()
+
+	f.Output.PrintSummary(f.Filter.Stats, time.Since(start), f.ShowStats)
+	if err := ctx.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted, results are partial")
+		return err
+	}
+	return nil
+}
+
+// walkRoots walk the roots until the whole trees are walked or ctx is done
+func (f *Yfind) walkRoots(ctx context.Context, fileChan chan fileJob, outputChan chan youtput.FileItem) {
+	// directory roots are walked concurrently, file roots are searched after the walkers start
+	queue := newDirQueue(f.Threads)
+	var fileRoots []os.FileInfo
+	var fileRootPaths []string
+	for i, root := range f.RootPaths {
+		info, ok := f.rootEntry(root)
+		if !ok {
+			continue
+		}
+		if !info.IsDir() {
+			fileRoots = append(fileRoots, info)
+			fileRootPaths = append(fileRootPaths, root)
+			continue
+		}
+		if f.MaxDepth != 0 {
+			queue.push(i%f.Threads, f.rootJob(root, info))
+		}
+	}
+
+	walkDone := make(chan struct{})
+	defer close(walkDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			queue.stop()
+		case <-walkDone:
+		}
+	}()
+
+	var walkerWg sync.WaitGroup
+	for i := 0; i < f.Threads; i++ {
+		walkerWg.Add(1)
+		go f.dirWalker(ctx, &walkerWg, i, queue, fileChan, outputChan)
+	}
+
+	// a file root is depth 0, the same as a directory root
+	for i, info := range fileRoots {
+		if f.MinDepth > 0 {
+			break
+		}
+		if !f.handOver(ctx, info, entryBaseDir(fileRootPaths[i], info), fileChan, outputChan) {
+			break
+		}
+	}
+	walkerWg.Wait()
+}
+
+// rootEntry stat the root, roots given on the command line are always resolved like ripgrep does,
+// the follow mode only applies to the entries found in the walk
+func (f *Yfind) rootEntry(root string) (os.FileInfo, bool) {
+	info, err := os.Stat(root)
+	if err != nil {
+		warn("%s: %s", root, err)
+		return nil, false
+	}
+	return info, true
+}
+
+// rootJob job of the root directory
+func (f *Yfind) rootJob(root string, info os.FileInfo) dirJob {
+	job := dirJob{path: root}
+	if !f.NoIgnore {
+		job.ignore = yignore.NewMatcher(root)
+	}
+	if f.rootSet != nil {
+		job.abs, _ = filepath.Abs(root)
+	}
+	if f.Follow == FollowAll {
+		job.ancestors = (*dirAncestor)(nil).child(info, root)
+	}
+	return job
+}
+
+// fileJob one file waiting for content scan
+type fileJob struct {
+	file os.FileInfo
+	path string
 }
 
-func (f *Yfind) workDir(path string, wg *sync.WaitGroup, outputChan chan youtput.FileItem) {
+// fileWorker content scan worker, runs until fileChan is closed
+// after ctx is cancelled the remaining jobs are drained without scanning
+func (f *Yfind) fileWorker(ctx context.Context, wg *sync.WaitGroup, fileChan chan fileJob, outputChan chan youtput.FileItem) {
 	defer wg.Done()
 
-	files, err := ioutil.ReadDir(path)
+	for job := range fileChan {
+		if ctx.Err() != nil {
+			continue
+		}
+		if pass, o := f.workFile(ctx, job.file, job.path); pass {
+			outputChan <- o
+		}
+	}
+}
+
+// dirWalker directory walker, runs until the whole tree is walked
+func (f *Yfind) dirWalker(ctx context.Context, wg *sync.WaitGroup, id int, queue *dirQueue, fileChan chan fileJob, outputChan chan youtput.FileItem) {
+	defer wg.Done()
+
+	for {
+		job, ok := queue.pop(id)
+		if !ok {
+			return
+		}
+		f.workDir(ctx, id, job, queue, fileChan, outputChan)
+		queue.done()
+	}
+}
+
+// workDir read one directory
+// sub directories are pushed back to the queue, files are filtered or handed over to content workers
+func (f *Yfind) workDir(ctx context.Context, id int, job dirJob, queue *dirQueue, fileChan chan fileJob, outputChan chan youtput.FileItem) {
+	f.Filter.Stats.AddDir()
+	files, err := ioutil.ReadDir(job.path)
 	if err != nil {
-		log.Printf("%s: %s\n", path, err)
+		log.Printf("%s: %s\n", job.path, err)
 	}
 
-	path = strings.TrimRight(path, "/") + "/"
+	path := strings.TrimRight(job.path, "/") + "/"
+	ignore := job.ignore
+	depth := job.depth + 1 // depth of entries in this directory
 
-	var filterContentWg sync.WaitGroup
 	for _, file := range files {
+		if ctx.Err() != nil {
+			return
+		}
 		fName := path + file.Name()
+		file, loop := f.resolveLink(file, fName, job.ancestors)
+		if loop != nil {
+			warn("%s: file system loop detected, it points to ancestor %s", fName, loop.path)
+			continue
+		}
+
+		// git internals are never searched, even with --hidden or --no-ignore
+		if file.IsDir() && file.Name() == ".git" {
+			continue
+		}
+		if !f.Hidden && strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		if ignore != nil && ignore.Ignored(fName, file.IsDir()) {
+			continue
+		}
+		abs := ""
+		if f.rootSet != nil {
+			abs = filepath.Join(job.abs, file.Name())
+			if _, ok := f.rootSet[abs]; ok { // walked as a root
+				continue
+			}
+		}
 
 		// work dir
 		if file.IsDir() {
-			wg.Add(1)
-			f.workDir(fName, wg, outputChan)
+			if f.Filter.SkipDir(file, path) {
+				continue
+			}
+			// directory as result, no content scan for it
+			if f.Filter.WantDirs() && depth >= f.MinDepth {
+				if pass, o := f.workFile(ctx, file, path); pass {
+					outputChan <- o
+				}
+			}
+			if f.MaxDepth >= 0 && depth >= f.MaxDepth {
+				continue
+			}
+			childIgnore := ignore
+			if ignore != nil {
+				childIgnore = ignore.Child(fName)
+			}
+			childJob := dirJob{path: fName, depth: depth, ignore: childIgnore, abs: abs}
+			if f.Follow == FollowAll {
+				childJob.ancestors = job.ancestors.child(file, fName)
+			}
+			queue.push(id, childJob)
+			continue
+		}
+		if depth < f.MinDepth {
 			continue
 		}
 
 		// work file
-		if f.Output.FilterFileContent == "" { // no content filter, no more goroutines
-			if pass, o := f.workFile(file, path); pass {
-				outputChan <- o
-			}
-		} else { // goroutines working on content filter
-			filterContentWg.Add(1)
-			go func(file os.FileInfo, path string, wg *sync.WaitGroup) {
-				defer wg.Done()
+		if !f.handOver(ctx, file, path, fileChan, outputChan) {
+			return
+		}
+	}
+}
 
-				if pass, o := f.workFile(file, path); pass {
-					outputChan <- o
-				}
-			}(file, path, &filterContentWg)
+// handOver filter file inline, or hand it over to content workers if content is searched
+// return false if ctx is done
+func (f *Yfind) handOver(ctx context.Context, file os.FileInfo, path string, fileChan chan fileJob, outputChan chan youtput.FileItem) bool {
+	if !f.Filter.SearchesContent() { // no content filter, no need to hand over to workers
+		if pass, o := f.workFile(ctx, file, path); pass {
+			outputChan <- o
 		}
+		return true
+	}
+	select { // content scan workers
+	case fileChan <- fileJob{file: file, path: path}:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-	filterContentWg.Wait()
 }
 
-func (f *Yfind) workFile(file os.FileInfo, path string) (p bool, o youtput.FileItem) {
-	return f.Filter.DoFilter(file, path)
+func (f *Yfind) workFile(ctx context.Context, file os.FileInfo, path string) (p bool, o youtput.FileItem) {
+	return f.Filter.DoFilter(ctx, file, path)
 }